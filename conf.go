@@ -0,0 +1,26 @@
+package jsonpath
+
+//以下常量原来定义在ihap-auth-sdk的conf包中，值是本包自己的取值，与sdk中的值不一定相同
+//调用方可能把这些值保存在配置中，不要修改已有的值，conf_test.go中固定了每个常量的值
+
+//LookupAndOperate的操作模式
+const (
+	//删除匹配到的字段
+	DataFieldControl = "data_field_control"
+	//脱敏匹配到的字段
+	DataDesensitizationControl = "data_desensitization_control"
+)
+
+//DesensitizationFuncs中内置的脱敏函数名
+const (
+	CarNumberDesensitization    = "car_number"
+	PhoneDesensitization        = "phone"
+	IdCardNumberDesensitization = "id_card_number"
+	NameDesensitization         = "name"
+	EmailDesensitization        = "email"
+	BankCardDesensitization     = "bank_card"
+	AddressDesensitization      = "address"
+	IPDesensitization           = "ip"
+	PassportDesensitization     = "passport"
+	TextPhoneDesensitization    = "text_phone"
+)
@@ -0,0 +1,35 @@
+package jsonpath
+
+import (
+	"testing"
+)
+
+//常量的值会被调用方保存在配置中，修改值属于不兼容的修改
+func Test_jsonpath_conf_values(t *testing.T) {
+	tcases := []struct {
+		Name   string
+		Got    string
+		Expect string
+	}{
+		{"DataFieldControl", DataFieldControl, "data_field_control"},
+		{"DataDesensitizationControl", DataDesensitizationControl, "data_desensitization_control"},
+		{"CarNumberDesensitization", CarNumberDesensitization, "car_number"},
+		{"PhoneDesensitization", PhoneDesensitization, "phone"},
+		{"IdCardNumberDesensitization", IdCardNumberDesensitization, "id_card_number"},
+		{"NameDesensitization", NameDesensitization, "name"},
+		{"EmailDesensitization", EmailDesensitization, "email"},
+		{"BankCardDesensitization", BankCardDesensitization, "bank_card"},
+		{"AddressDesensitization", AddressDesensitization, "address"},
+		{"IPDesensitization", IPDesensitization, "ip"},
+		{"PassportDesensitization", PassportDesensitization, "passport"},
+		{"TextPhoneDesensitization", TextPhoneDesensitization, "text_phone"},
+	}
+	for idx, tcase := range tcases {
+		if tcase.Got != tcase.Expect {
+			t.Errorf("idx: %d, %s: (got)%v != (exp)%v", idx, tcase.Name, tcase.Got, tcase.Expect)
+		}
+		if _, ok := DesensitizationFuncs[tcase.Got]; !ok && idx > 1 {
+			t.Errorf("idx: %d, %s not registered in DesensitizationFuncs", idx, tcase.Name)
+		}
+	}
+}
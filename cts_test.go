@@ -0,0 +1,217 @@
+package jsonpath
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+//上游JSONPath Compliance Test Suite的cts.json放在ctsVendorFile，LICENSE和COMMIT(上游的commit hash)放在同一个目录
+//没有上游文件时使用手写的子集ctsSubsetFile，格式与上游一致，不是上游某个版本的原样拷贝，通过子集不代表通过上游的用例
+//环境变量JSONPATH_CTS可以指定其它的cts.json
+const (
+	ctsVendorFile = "testdata/jsonpath-compliance-test-suite/cts.json"
+	ctsSubsetFile = "testdata/cts_subset.json"
+)
+
+//已知不通过的用例，rfc9535和legacy分别对应CompileRFC9535和Compile，键为用例名，值为原因
+//修复后需要从这里删除，否则测试会报错；更换用例文件后用 go test -run compliance -cts.update 重新生成
+const ctsKnownFailuresFile = "testdata/cts_known_failures.json"
+
+var ctsUpdate = flag.Bool("cts.update", false, "rewrite "+ctsKnownFailuresFile+" with the failing compliance cases")
+
+//要执行的用例文件
+func ctsFile() string {
+	if file := os.Getenv("JSONPATH_CTS"); file != "" {
+		return file
+	}
+	if _, err := os.Stat(ctsVendorFile); err == nil {
+		return ctsVendorFile
+	}
+	return ctsSubsetFile
+}
+
+func ctsKnownFailures(t *testing.T) map[string]map[string]string {
+	data, err := ioutil.ReadFile(ctsKnownFailuresFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var known map[string]map[string]string
+	if err := json.Unmarshal(data, &known); err != nil {
+		t.Fatal(err)
+	}
+	return known
+}
+
+//把mode的已知失败用例写回ctsKnownFailuresFile，其它模式的列表保持不变
+func ctsWriteKnownFailures(t *testing.T, mode string, failures map[string]string) {
+	known := ctsKnownFailures(t)
+	known[mode] = failures
+	data, err := json.MarshalIndent(known, "", "  ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(ctsKnownFailuresFile, append(data, '\n'), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+//失败原因的分类
+func ctsFailureReason(msg string) string {
+	switch {
+	case msg == "selector should be invalid":
+		return "accepts selector rejected by RFC 9535"
+	case strings.HasPrefix(msg, "compile error"):
+		return "syntax not supported"
+	case strings.HasPrefix(msg, "lookup error"):
+		return "lookup returns error"
+	case strings.HasPrefix(msg, "panic"):
+		return "panic"
+	}
+	return "different result"
+}
+
+//单个用例
+//result 期望的结果；结果顺序不确定时使用results列出所有可能的结果
+//result_paths/results_paths 期望结果的规范化路径
+//invalid_selector 为true时selector应该解析失败
+type ctsCase struct {
+	Name            string          `json:"name"`
+	Selector        string          `json:"selector"`
+	Document        interface{}     `json:"document"`
+	Result          []interface{}   `json:"result"`
+	Results         [][]interface{} `json:"results"`
+	ResultPaths     []string        `json:"result_paths"`
+	ResultsPaths    [][]string      `json:"results_paths"`
+	InvalidSelector bool            `json:"invalid_selector"`
+}
+
+func Test_jsonpath_compliance_suite(t *testing.T) {
+	runCtsSuite(t, CompileRFC9535, "rfc9535")
+}
+
+//用同一套用例检查Compile，Lookup的结果不是节点列表，只检查能否得到等价的值
+func Test_jsonpath_compliance_suite_legacy(t *testing.T) {
+	runCtsSuite(t, Compile, "legacy")
+}
+
+func runCtsSuite(t *testing.T, compile func(string, ...Option) (*Compiled, error), mode string) {
+	knownFailures := ctsKnownFailures(t)[mode]
+	file := ctsFile()
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var suite struct {
+		Tests []ctsCase `json:"tests"`
+	}
+	if err := json.Unmarshal(data, &suite); err != nil {
+		t.Fatal(err)
+	}
+	passed, failed, known := 0, 0, 0
+	failures := map[string]string{}
+	for _, tcase := range suite.Tests {
+		reason, isKnown := knownFailures[tcase.Name]
+		msg := runCtsCase(tcase, compile)
+		if msg != "" {
+			if !isKnown {
+				reason = ctsFailureReason(msg)
+			}
+			failures[tcase.Name] = reason
+		}
+		if *ctsUpdate {
+			continue
+		}
+		switch {
+		case msg == "" && isKnown:
+			failed++
+			t.Errorf("%s: passes now, remove it from known failures", tcase.Name)
+		case msg == "":
+			passed++
+		case isKnown:
+			known++
+			t.Logf("%s: known failure (%s): %s", tcase.Name, reason, msg)
+		default:
+			failed++
+			t.Errorf("%s: %s", tcase.Name, msg)
+		}
+	}
+	if *ctsUpdate {
+		ctsWriteKnownFailures(t, mode, failures)
+		t.Logf("compliance suite %s: %d failures written to %s", file, len(failures), ctsKnownFailuresFile)
+		return
+	}
+	t.Logf("compliance suite %s: %d passed, %d failed, %d known failures, %d total", file, passed, failed, known, len(suite.Tests))
+}
+
+//执行单个用例，通过时返回空字符串，否则返回失败原因
+func runCtsCase(tcase ctsCase, compile func(string, ...Option) (*Compiled, error)) (msg string) {
+	defer func() {
+		if r := recover(); r != nil {
+			msg = fmt.Sprintf("panic: %v", r)
+		}
+	}()
+	c, err := compile(tcase.Selector)
+	if tcase.InvalidSelector {
+		if err == nil {
+			return "selector should be invalid"
+		}
+		return ""
+	}
+	if err != nil {
+		return "compile error: " + err.Error()
+	}
+	results := tcase.Results
+	if results == nil {
+		results = [][]interface{}{tcase.Result}
+	}
+	res, err := c.Lookup(tcase.Document)
+	if err != nil {
+		//Compile模式下找不到数据时返回错误
+		if c.query == nil && len(results[0]) == 0 {
+			return ""
+		}
+		return "lookup error: " + err.Error()
+	}
+	matched := -1
+	for i, expect := range results {
+		//Compile模式下单个结果不会包装成数组
+		if valueEqual(res, expect) || (c.query == nil && valueEqual([]interface{}{res}, expect)) {
+			matched = i
+			break
+		}
+	}
+	if matched < 0 {
+		return "result " + toString(res) + " not in " + toString(results)
+	}
+	//顺序不确定的用例只检查与结果对应的路径
+	var paths []string
+	if tcase.ResultsPaths != nil {
+		paths = tcase.ResultsPaths[matched]
+	} else if tcase.ResultPaths != nil {
+		paths = tcase.ResultPaths
+	}
+	if paths != nil && c.query != nil {
+		got := []string{}
+		for _, n := range evalQuery(c.query, tcase.Document, tcase.Document) {
+			got = append(got, n.path())
+		}
+		if !reflect.DeepEqual(got, paths) {
+			return "paths " + toString(got) + " != " + toString(paths)
+		}
+	}
+	return ""
+}
+
+func toString(v interface{}) string {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err.Error()
+	}
+	return string(data)
+}
@@ -0,0 +1,151 @@
+package jsonpath
+
+import (
+	"fmt"
+	"net"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+//邮箱格式，@前后都不能为空
+var emailReg = regexp.MustCompile(`^[^@\s]+@[^@\s]+$`)
+
+//邮箱脱敏函数，只保留用户名的第一个字符
+//用户名只有一个字符时全部脱敏，a@example.com => *@example.com
+func emailDesensitization(jsonMap map[string]interface{}, key string) error {
+	value, err := mask_value(jsonMap, key)
+	if err != nil {
+		return err
+	}
+	if !emailReg.MatchString(value) {
+		return fmt.Errorf("email error")
+	}
+	rule := MaskRule{Pattern: `^.([^@]*)@`}
+	if utf8.RuneCountInString(value[:strings.IndexByte(value, '@')]) == 1 {
+		rule = MaskRule{Pattern: `^([^@])@`}
+	}
+	return handle_desensitization(jsonMap, key, rule)
+}
+
+//银行卡号脱敏函数，保留前6位(发卡行识别码)和后4位
+//卡号为12到19位数字，可以用空格或-分隔，需要通过Luhn校验
+func bankCardDesensitization(jsonMap map[string]interface{}, key string) error {
+	str, err := mask_value(jsonMap, key)
+	if err != nil {
+		return err
+	}
+	value := []rune(str)
+	digits := []rune{}
+	for _, c := range value {
+		if c >= '0' && c <= '9' {
+			digits = append(digits, c)
+		} else if c != ' ' && c != '-' {
+			return fmt.Errorf("bankcard error")
+		}
+	}
+	if len(digits) < 12 || len(digits) > 19 || !luhn_valid(digits) {
+		return fmt.Errorf("bankcard error")
+	}
+	//分隔符保持不变，只脱敏中间的数字
+	idx := 0
+	for i, c := range value {
+		if c < '0' || c > '9' {
+			continue
+		}
+		if idx >= 6 && idx < len(digits)-4 {
+			value[i] = '*'
+		}
+		idx++
+	}
+	jsonMap[key] = string(value)
+	return nil
+}
+
+//Luhn校验，从最后一位开始偶数位乘2，各位之和需要能被10整除
+func luhn_valid(digits []rune) bool {
+	sum := 0
+	for i := len(digits) - 1; i >= 0; i-- {
+		d := int(digits[i] - '0')
+		if (len(digits)-i)%2 == 0 {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+	}
+	return sum%10 == 0
+}
+
+//地址脱敏策略，保留前6个字符(一般为省市区)，其余部分固定替换为4个*，不暴露门牌号和长度
+var addressStrategy = &MaskStrategy{
+	Name: AddressDesensitization,
+	Rules: []MaskRule{
+		{MinLength: 7, KeepFirst: 6, FixedLength: 4},
+		{KeepFirst: 1, FixedLength: 4},
+	},
+}
+
+//IP地址脱敏函数
+//IPv4脱敏最后一段，例如192.168.1.*；IPv6只保留前4段(网络前缀)，例如2001:db8:0:1:*:*:*:*
+func ipDesensitization(jsonMap map[string]interface{}, key string) error {
+	value, err := mask_value(jsonMap, key)
+	if err != nil {
+		return err
+	}
+	ip := net.ParseIP(value)
+	if ip == nil {
+		return fmt.Errorf("ip error")
+	}
+	//包括::ffff:1.2.3.4形式的IPv4地址
+	if ip4 := ip.To4(); ip4 != nil {
+		jsonMap[key] = fmt.Sprintf("%d.%d.%d.*", ip4[0], ip4[1], ip4[2])
+		return nil
+	}
+	groups := make([]string, 8)
+	for i := range groups {
+		if i < 4 {
+			groups[i] = fmt.Sprintf("%x", int(ip[2*i])<<8|int(ip[2*i+1]))
+		} else {
+			groups[i] = "*"
+		}
+	}
+	jsonMap[key] = strings.Join(groups, ":")
+	return nil
+}
+
+//护照号脱敏策略，保留前2位和后3位，例如E1****678
+var passportStrategy = &MaskStrategy{
+	Name: PassportDesensitization,
+	Rules: []MaskRule{
+		{MinLength: 6, KeepFirst: 2, KeepLast: 3},
+		//过短的号码只保留第1位
+		{KeepFirst: 1},
+	},
+}
+
+//文本中连续的数字
+var digitsReg = regexp.MustCompile(`\d+`)
+
+//文本中手机号脱敏函数
+//连续的11位数字且以13-19开头时视为手机号，带86前缀的13位数字同样处理，只脱敏中间4位
+func textPhoneDesensitization(jsonMap map[string]interface{}, key string) error {
+	str, err := mask_value(jsonMap, key)
+	if err != nil {
+		return err
+	}
+	value := []byte(str)
+	for _, loc := range digitsReg.FindAllIndex(value, -1) {
+		number := value[loc[0]:loc[1]]
+		if len(number) == 13 && string(number[:2]) == "86" {
+			number = number[2:]
+		}
+		if len(number) != 11 || number[0] != '1' || number[1] < '3' {
+			continue
+		}
+		copy(number[3:7], "****")
+	}
+	jsonMap[key] = string(value)
+	return nil
+}
@@ -0,0 +1,80 @@
+package jsonpath
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func Test_jsonpath_desensitization_funcs(t *testing.T) {
+	tcases := []struct {
+		Func   string
+		Value  string
+		Expect string
+		Err    bool
+	}{
+		{EmailDesensitization, "alice@example.com", "a****@example.com", false},
+		{EmailDesensitization, "a@example.com", "*@example.com", false},
+		{EmailDesensitization, "张@example.com", "*@example.com", false},
+		{EmailDesensitization, "ab@example.com", "a*@example.com", false},
+		{EmailDesensitization, "alice.example.com", "", true},
+		{EmailDesensitization, "alice@", "", true},
+		{BankCardDesensitization, "4111111111111111", "411111******1111", false},
+		{BankCardDesensitization, "6222 0212 3456 7890 128", "6222 02** **** ***0 128", false},
+		{BankCardDesensitization, "4111-1111-1111-1111", "4111-11**-****-1111", false},
+		{BankCardDesensitization, "4111111111111112", "", true},
+		{BankCardDesensitization, "41111111111", "", true},
+		{BankCardDesensitization, "4111a11111111111", "", true},
+		{AddressDesensitization, "北京市海淀区中关村大街27号", "北京市海淀区****", false},
+		{AddressDesensitization, "221B Baker Street", "221B B****", false},
+		{AddressDesensitization, "上海", "上****", false},
+		{IPDesensitization, "192.168.1.23", "192.168.1.*", false},
+		{IPDesensitization, "::ffff:10.0.0.1", "10.0.0.*", false},
+		{IPDesensitization, "2001:db8::1", "2001:db8:0:0:*:*:*:*", false},
+		{IPDesensitization, "fe80:0:0:1:a:b:c:d", "fe80:0:0:1:*:*:*:*", false},
+		{IPDesensitization, "256.1.1.1", "", true},
+		{IPDesensitization, "localhost", "", true},
+		{PassportDesensitization, "E12345678", "E1****678", false},
+		{PassportDesensitization, "G1234", "G****", false},
+		{TextPhoneDesensitization, "请联系13800138000或+8613900139000", "请联系138****8000或+86139****9000", false},
+		{TextPhoneDesensitization, "13800138000,13900139000", "138****8000,139****9000", false},
+		{TextPhoneDesensitization, "电话010-88888888，订单12345678901、138001380001", "电话010-88888888，订单12345678901、138001380001", false},
+	}
+	for idx, tcase := range tcases {
+		f, ok := DesensitizationFuncs[tcase.Func]
+		if !ok {
+			t.Errorf("idx: %d, %s not registered", idx, tcase.Func)
+			continue
+		}
+		jsonMap := map[string]interface{}{"v": tcase.Value}
+		err := f(jsonMap, "v")
+		if tcase.Err {
+			if err == nil {
+				t.Errorf("idx: %d, %s: %s, error not raised", idx, tcase.Func, tcase.Value)
+			}
+			continue
+		}
+		if err != nil || jsonMap["v"] != tcase.Expect {
+			t.Errorf("idx: %d, %s: %s, (got)%v != (exp)%v, err: %v", idx, tcase.Func, tcase.Value, jsonMap["v"], tcase.Expect, err)
+		}
+	}
+}
+
+func Test_jsonpath_desensitization_funcs_lookup(t *testing.T) {
+	var j interface{}
+	json.Unmarshal([]byte(`{"users": [{"email": "bob@example.com", "ips": ["10.1.2.3", "2001:db8::1"]}], "remark": "call 13800138000"}`), &j)
+	for _, tcase := range []struct{ Path, Func string }{
+		{"$..email", EmailDesensitization},
+		{"$.users[*].ips[*]", IPDesensitization},
+		{"$.remark", TextPhoneDesensitization},
+	} {
+		if _, err := JsonPathLookUpAndDesensitization(j, tcase.Path, tcase.Func); err != nil {
+			t.Fatalf("%s: %v", tcase.Path, err)
+		}
+	}
+	var expect interface{}
+	json.Unmarshal([]byte(`{"users": [{"email": "b**@example.com", "ips": ["10.1.2.*", "2001:db8:0:0:*:*:*:*"]}], "remark": "call 138****8000"}`), &expect)
+	if !reflect.DeepEqual(j, expect) {
+		t.Errorf("(got)%v != (exp)%v", toString(j), toString(expect))
+	}
+}
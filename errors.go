@@ -0,0 +1,119 @@
+package jsonpath
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+//jsonpath语法错误，Compile解析失败时返回
+//Path 出错的jsonpath
+//Offset 出错位置在Path中的字节偏移
+//Line 出错位置所在行，从1开始
+//Column 出错位置所在列，按字符计算，从1开始
+//Token 出错位置的原始内容，到达末尾时为空
+//Expected 该位置期望出现的内容
+//Msg 错误描述
+type SyntaxError struct {
+	Path     string
+	Offset   int
+	Line     int
+	Column   int
+	Token    string
+	Expected []string
+	Msg      string
+}
+
+func newSyntaxError(path string, offset int, token string, msg string, expected ...string) *SyntaxError {
+	if offset > len(path) {
+		offset = len(path)
+	}
+	line := 1 + strings.Count(path[:offset], "\n")
+	lineStart := strings.LastIndex(path[:offset], "\n") + 1
+	return &SyntaxError{
+		Path:     path,
+		Offset:   offset,
+		Line:     line,
+		Column:   utf8.RuneCountInString(path[lineStart:offset]) + 1,
+		Token:    token,
+		Expected: expected,
+		Msg:      msg,
+	}
+}
+
+func (e *SyntaxError) Error() string {
+	msg := fmt.Sprintf("syntax error at line %d, column %d: %s", e.Line, e.Column, e.Msg)
+	if len(e.Expected) > 0 {
+		msg += ", expected " + strings.Join(e.Expected, " or ")
+	}
+	return msg
+}
+
+//返回带有出错行和'^'标记的多行错误描述，例如:
+//  syntax error at line 1, column 5: unexpected ']', expected name or '*' or '['
+//  $.a.]
+//      ^
+func (e *SyntaxError) Pretty() string {
+	lineStart := strings.LastIndex(e.Path[:e.Offset], "\n") + 1
+	lineEnd := strings.Index(e.Path[e.Offset:], "\n")
+	if lineEnd < 0 {
+		lineEnd = len(e.Path)
+	} else {
+		lineEnd += e.Offset
+	}
+	//按显示宽度对齐'^'，制表符原样保留
+	var caret strings.Builder
+	for _, r := range e.Path[lineStart:e.Offset] {
+		if r == '\t' {
+			caret.WriteRune('\t')
+		} else {
+			caret.WriteString(strings.Repeat(" ", runeWidth(r)))
+		}
+	}
+	caret.WriteRune('^')
+	return e.Error() + "\n" + e.Path[lineStart:lineEnd] + "\n" + caret.String()
+}
+
+//字符在终端中的显示宽度，中日韩等全角字符占两列
+func runeWidth(r rune) int {
+	switch {
+	case r >= 0x1100 && r <= 0x115F,
+		r >= 0x2E80 && r <= 0xA4CF,
+		r >= 0xAC00 && r <= 0xD7A3,
+		r >= 0xF900 && r <= 0xFAFF,
+		r >= 0xFE30 && r <= 0xFE4F,
+		r >= 0xFF00 && r <= 0xFF60,
+		r >= 0xFFE0 && r <= 0xFFE6,
+		r >= 0x20000 && r <= 0x3FFFD:
+		return 2
+	}
+	return 1
+}
+
+//脱敏的值不是字符串时返回的错误
+//Key 值所在的键(string)或数组下标(int)
+//Value 原来的值
+type MaskTypeError struct {
+	Key   interface{}
+	Value interface{}
+}
+
+func (e *MaskTypeError) Error() string {
+	var kind string
+	switch e.Value.(type) {
+	case nil:
+		kind = "null"
+	case bool:
+		kind = "boolean"
+	case map[string]interface{}:
+		kind = "object"
+	case []interface{}:
+		kind = "array"
+	default:
+		kind = fmt.Sprintf("%T", e.Value)
+		if _, ok := stringify_number(e.Value); ok {
+			kind = "number"
+		}
+	}
+	return fmt.Sprintf("mask error: value of %v is %s, not string", e.Key, kind)
+}
@@ -0,0 +1,95 @@
+package jsonpath
+
+import (
+	"reflect"
+	"testing"
+)
+
+var tcase_syntax_error = []struct {
+	Path     string
+	Offset   int
+	Line     int
+	Column   int
+	Token    string
+	Expected []string
+	Pretty   string
+}{
+	{
+		Path:     "$.a.]",
+		Offset:   4,
+		Line:     1,
+		Column:   5,
+		Token:    "]",
+		Expected: []string{"name", "'*'", "'['"},
+		Pretty: "syntax error at line 1, column 5: unexpected ']' after '.', expected name or '*' or '['\n" +
+			"$.a.]\n" +
+			"    ^",
+	},
+	{
+		Path:     "store.book",
+		Offset:   0,
+		Line:     1,
+		Column:   1,
+		Token:    "store",
+		Expected: []string{"'$'", "'@'"},
+	},
+	{
+		Path:     "$.用户[1;",
+		Offset:   10,
+		Line:     1,
+		Column:   7,
+		Token:    ";",
+		Expected: nil,
+		Pretty: "syntax error at line 1, column 7: invalid character ';'\n" +
+			"$.用户[1;\n" +
+			"        ^",
+	},
+	{
+		Path:     "$.a[?(@.b == 1\n  && @.c == 2]",
+		Offset:   28,
+		Line:     2,
+		Column:   14,
+		Token:    "]",
+		Expected: []string{"')'"},
+	},
+	{
+		Path:     "$.a[?(@.b == 'x)]",
+		Offset:   13,
+		Line:     1,
+		Column:   14,
+		Token:    "'x)]",
+		Expected: []string{"closing quote"},
+	},
+	{
+		Path:     "$.a[1",
+		Offset:   5,
+		Line:     1,
+		Column:   6,
+		Token:    "",
+		Expected: []string{"','", "']'"},
+	},
+}
+
+func Test_jsonpath_syntax_error(t *testing.T) {
+	for idx, tcase := range tcase_syntax_error {
+		_, err := Compile(tcase.Path)
+		t.Logf("idx: %d, err: %v", idx, err)
+		serr, ok := err.(*SyntaxError)
+		if ok != true {
+			t.Errorf("idx: %d, should return *SyntaxError, got: %#v", idx, err)
+			continue
+		}
+		if serr.Path != tcase.Path || serr.Offset != tcase.Offset || serr.Line != tcase.Line || serr.Column != tcase.Column {
+			t.Errorf("idx: %d, position: (got)%d %d:%d != (exp)%d %d:%d", idx, serr.Offset, serr.Line, serr.Column, tcase.Offset, tcase.Line, tcase.Column)
+		}
+		if serr.Token != tcase.Token {
+			t.Errorf("idx: %d, token: (got)%q != (exp)%q", idx, serr.Token, tcase.Token)
+		}
+		if !reflect.DeepEqual(serr.Expected, tcase.Expected) {
+			t.Errorf("idx: %d, expected: (got)%v != (exp)%v", idx, serr.Expected, tcase.Expected)
+		}
+		if tcase.Pretty != "" && serr.Pretty() != tcase.Pretty {
+			t.Errorf("idx: %d, pretty:\n(got)\n%s\n(exp)\n%s", idx, serr.Pretty(), tcase.Pretty)
+		}
+	}
+}
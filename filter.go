@@ -0,0 +1,636 @@
+package jsonpath
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+//过滤表达式语法树节点
+//kind 节点类型(必须，有:or,and,not,compare,query,func,literal)
+//op compare节点的比较操作符，Compile模式下还有 =~ in noin
+//args or/and/compare节点的两个操作数，not节点的操作数，func节点的参数
+//query query节点中以'@'或'$'开头的路径
+//steps Compile模式下query节点执行的步骤
+//fn func节点的函数名
+//value literal节点的值，=~ 的右操作数为*regexp.Regexp，in/noin的右操作数为[]interface{}
+//coerce compare节点比较前是否把数字形式的字符串转换成数字
+//pos 在jsonpath中的字节偏移
+type filterExpr struct {
+	kind   string
+	op     string
+	args   []*filterExpr
+	query  *pathNode
+	steps  []step
+	fn     string
+	value  interface{}
+	coerce bool
+	pos    int
+}
+
+//过滤表达式的文本形式，用于调试和测试
+func (e *filterExpr) String() string {
+	switch e.kind {
+	case "or":
+		return e.args[0].operandString(e.kind) + " || " + e.args[1].operandString(e.kind)
+	case "and":
+		return e.args[0].operandString(e.kind) + " && " + e.args[1].operandString(e.kind)
+	case "not":
+		return "!" + e.args[0].operandString(e.kind)
+	case "compare":
+		return e.args[0].String() + " " + e.op + " " + e.args[1].String()
+	case "query":
+		return e.query.String()
+	case "func":
+		args := make([]string, len(e.args))
+		for i, arg := range e.args {
+			args[i] = arg.String()
+		}
+		return e.fn + "(" + strings.Join(args, ", ") + ")"
+	}
+	switch v := e.value.(type) {
+	case nil:
+		return "null"
+	case string:
+		return "'" + escapeName(v) + "'"
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	case *regexp.Regexp:
+		return "/" + v.String() + "/"
+	case []interface{}:
+		items := make([]string, len(v))
+		for i, item := range v {
+			items[i] = (&filterExpr{kind: "literal", value: item}).String()
+		}
+		return "{" + strings.Join(items, ", ") + "}"
+	}
+	return ""
+}
+
+//作为parent的操作数时的文本形式，优先级更低的表达式需要加括号
+func (e *filterExpr) operandString(parent string) string {
+	if (parent == "and" && e.kind == "or") || (parent == "not" && (e.kind == "or" || e.kind == "and" || e.kind == "compare")) {
+		return "(" + e.String() + ")"
+	}
+	return e.String()
+}
+
+//过滤表达式中参数和返回值的类型
+type exprType int
+
+const (
+	valueType   exprType = iota //json值，可能是Nothing
+	logicalType                 //逻辑值
+	nodesType                   //节点列表
+)
+
+//Nothing，表示不存在的值，和json中的null不同
+type nothingValue struct{}
+
+var nothing = nothingValue{}
+
+//过滤表达式中可以调用的函数
+//params 参数类型
+//result 返回值类型
+//call 具体实现，参数按类型传入: valueType为值或nothing，logicalType为bool，nodesType为[]*node
+type filterFunc struct {
+	params []exprType
+	result exprType
+	call   func(args []interface{}) interface{}
+}
+
+//RFC 9535中定义的函数
+var filterFuncs = map[string]filterFunc{
+	"length": {[]exprType{valueType}, valueType, funcLength},
+	"count":  {[]exprType{nodesType}, valueType, funcCount},
+	"match":  {[]exprType{valueType, valueType}, logicalType, funcMatch},
+	"search": {[]exprType{valueType, valueType}, logicalType, funcSearch},
+	"value":  {[]exprType{nodesType}, valueType, funcValue},
+}
+
+//比较操作符
+var comparisonOps = map[string]bool{"==": true, "!=": true, "<": true, "<=": true, ">": true, ">=": true}
+
+//logical-or-expr = logical-and-expr ('||' logical-and-expr)*
+func (p *parser) parseLogicalExpr() (*filterExpr, error) {
+	left, err := p.parseLogicalAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().kind == tokOperator && p.peek().val == "||" {
+		op := p.next()
+		right, err := p.parseLogicalAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = &filterExpr{kind: "or", args: []*filterExpr{left, right}, pos: op.pos}
+	}
+	return left, nil
+}
+
+//logical-and-expr = basic-expr ('&&' basic-expr)*
+func (p *parser) parseLogicalAnd() (*filterExpr, error) {
+	left, err := p.parseBasicExpr()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().kind == tokOperator && p.peek().val == "&&" {
+		op := p.next()
+		right, err := p.parseBasicExpr()
+		if err != nil {
+			return nil, err
+		}
+		left = &filterExpr{kind: "and", args: []*filterExpr{left, right}, pos: op.pos}
+	}
+	return left, nil
+}
+
+//basic-expr = ['!'] '(' logical-expr ')' | ['!'] test-expr | comparable op comparable
+func (p *parser) parseBasicExpr() (*filterExpr, error) {
+	tok := p.peek()
+	if tok.kind == tokOperator && tok.val == "!" {
+		p.next()
+		var operand *filterExpr
+		var err error
+		if p.peek().kind == tokLParen {
+			operand, err = p.parseParenExpr()
+		} else {
+			operand, err = p.parseComparable()
+			if err == nil && operand.kind == "literal" {
+				err = p.fail(p.tokens[p.cur-1], "'!' can not be applied to literal")
+			}
+			if next := p.peek(); err == nil && next.kind == tokOperator && comparisonOps[next.val] {
+				err = p.fail(next, "'!' can not be applied to comparison, use '!(...)'")
+			}
+		}
+		if err != nil {
+			return nil, err
+		}
+		return &filterExpr{kind: "not", args: []*filterExpr{operand}, pos: tok.pos}, nil
+	}
+	if tok.kind == tokLParen {
+		return p.parseParenExpr()
+	}
+	left, err := p.parseComparable()
+	if err != nil {
+		return nil, err
+	}
+	op := p.peek()
+	//兼容原有的 =~ /pattern/ 以及 in {...}、noin {...}
+	if !p.rfc && op.kind == tokOperator && op.val == "=~" {
+		return p.parseRegexpMatch(left)
+	}
+	if !p.rfc && op.kind == tokName && (op.val == "in" || op.val == "noin") {
+		return p.parseInExpr(left)
+	}
+	if op.kind != tokOperator || !comparisonOps[op.val] {
+		return left, nil
+	}
+	p.next()
+	right, err := p.parseComparable()
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range []*filterExpr{left, right} {
+		if err := p.checkArg(e, valueType); err != nil {
+			return nil, err
+		}
+	}
+	return &filterExpr{kind: "compare", op: op.val, args: []*filterExpr{left, right}, pos: op.pos}, nil
+}
+
+//regexp-match = comparable '=~' '/pattern/flags'
+func (p *parser) parseRegexpMatch(left *filterExpr) (*filterExpr, error) {
+	op := p.next()
+	tok := p.next()
+	if tok.kind != tokRegexp {
+		return nil, p.fail(tok, "unexpected "+p.describe(tok)+" after '=~'", tokRegexp.String())
+	}
+	re, err := regFilterCompile(tok.val)
+	if err != nil {
+		return nil, p.fail(tok, "invalid regexp "+p.describe(tok)+": "+err.Error())
+	}
+	right := &filterExpr{kind: "literal", value: re, pos: tok.pos}
+	return &filterExpr{kind: "compare", op: op.val, args: []*filterExpr{left, right}, pos: op.pos}, nil
+}
+
+//in-expr = comparable ('in' | 'noin') '{' literal (',' literal)* '}'
+func (p *parser) parseInExpr(left *filterExpr) (*filterExpr, error) {
+	op := p.next()
+	lbrace, err := p.expect(tokLBrace)
+	if err != nil {
+		return nil, err
+	}
+	values := []interface{}{}
+	for {
+		item, err := p.parseComparable()
+		if err != nil {
+			return nil, err
+		}
+		if item.kind != "literal" {
+			return nil, newSyntaxError(p.path, item.pos, "", "only literal is allowed in '{...}'")
+		}
+		values = append(values, item.value)
+		tok := p.next()
+		if tok.kind == tokRBrace {
+			break
+		}
+		if tok.kind != tokComma {
+			return nil, p.fail(tok, "unexpected "+p.describe(tok)+" in '{...}'", tokComma.String(), tokRBrace.String())
+		}
+	}
+	right := &filterExpr{kind: "literal", value: values, pos: lbrace.pos}
+	return &filterExpr{kind: "compare", op: op.val, args: []*filterExpr{left, right}, pos: op.pos}, nil
+}
+
+//paren-expr = '(' logical-expr ')'
+func (p *parser) parseParenExpr() (*filterExpr, error) {
+	if _, err := p.expect(tokLParen); err != nil {
+		return nil, err
+	}
+	expr, err := p.parseLogicalExpr()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.checkLogical(expr); err != nil {
+		return nil, err
+	}
+	if _, err := p.expect(tokRParen); err != nil {
+		return nil, err
+	}
+	return expr, nil
+}
+
+//comparable = literal | query | function-expr
+func (p *parser) parseComparable() (*filterExpr, error) {
+	tok := p.peek()
+	switch tok.kind {
+	case tokRoot, tokCurrent:
+		query, err := p.parseQuery()
+		if err != nil {
+			return nil, err
+		}
+		expr := &filterExpr{kind: "query", query: query, pos: tok.pos}
+		if !p.rfc {
+			expr.steps, err = buildSteps(query)
+			if err != nil {
+				return nil, err
+			}
+		}
+		return expr, nil
+	case tokString, tokNumber:
+		return p.parseLiteral()
+	case tokName:
+		switch tok.val {
+		case "true", "false", "null":
+			return p.parseLiteral()
+		}
+		if !p.rfc {
+			//Compile模式下不支持函数，没有加引号的名字作为字符串
+			if next := p.peekAt(1); next.kind == tokLParen && next.pos == tok.end {
+				return nil, p.fail(tok, "function is not supported, use CompileRFC9535")
+			}
+			p.next()
+			return &filterExpr{kind: "literal", value: tok.val, pos: tok.pos}, nil
+		}
+		return p.parseFunction()
+	}
+	return nil, p.fail(tok, "unexpected "+p.describe(tok)+" in filter", tokCurrent.String(), tokRoot.String(), tokString.String(), tokNumber.String(), "function")
+}
+
+//literal = number | string | true | false | null
+func (p *parser) parseLiteral() (*filterExpr, error) {
+	tok := p.next()
+	expr := &filterExpr{kind: "literal", pos: tok.pos}
+	switch tok.kind {
+	case tokString:
+		expr.value = tok.val
+	case tokNumber:
+		//RFC 9535中的数字不能有前导0
+		digits := strings.TrimPrefix(tok.val, "-")
+		if p.rfc && len(digits) > 1 && digits[0] == '0' && digits[1] >= '0' && digits[1] <= '9' {
+			return nil, p.fail(tok, "invalid number "+p.describe(tok))
+		}
+		f, err := strconv.ParseFloat(tok.val, 64)
+		if err != nil {
+			return nil, p.fail(tok, "invalid number "+p.describe(tok))
+		}
+		expr.value = f
+	case tokName:
+		switch tok.val {
+		case "true":
+			expr.value = true
+		case "false":
+			expr.value = false
+		case "null":
+			expr.value = nil
+		}
+	}
+	return expr, nil
+}
+
+//function-expr = name '(' [argument (',' argument)*] ')'
+func (p *parser) parseFunction() (*filterExpr, error) {
+	name := p.next()
+	fn, ok := filterFuncs[name.val]
+	if !ok {
+		return nil, p.fail(name, "unknown function "+p.describe(name))
+	}
+	lparen := p.peek()
+	if lparen.kind != tokLParen || lparen.pos != name.end {
+		return nil, p.fail(lparen, "unexpected "+p.describe(lparen)+" after function name", tokLParen.String())
+	}
+	p.next()
+	expr := &filterExpr{kind: "func", fn: name.val, args: []*filterExpr{}, pos: name.pos}
+	if p.peek().kind == tokRParen {
+		p.next()
+	} else {
+		for {
+			arg, err := p.parseFunctionArg()
+			if err != nil {
+				return nil, err
+			}
+			expr.args = append(expr.args, arg)
+			tok := p.next()
+			if tok.kind == tokRParen {
+				break
+			}
+			if tok.kind != tokComma {
+				return nil, p.fail(tok, "unexpected "+p.describe(tok)+" in function arguments", tokComma.String(), tokRParen.String())
+			}
+		}
+	}
+	if len(expr.args) != len(fn.params) {
+		return nil, p.fail(name, "function "+p.describe(name)+" expects "+strconv.Itoa(len(fn.params))+" argument(s)")
+	}
+	for i, arg := range expr.args {
+		if err := p.checkArg(arg, fn.params[i]); err != nil {
+			return nil, err
+		}
+	}
+	return expr, nil
+}
+
+//function-argument = literal | filter-query | logical-expr | function-expr
+func (p *parser) parseFunctionArg() (*filterExpr, error) {
+	tok := p.peek()
+	literal := tok.kind == tokString || tok.kind == tokNumber ||
+		(tok.kind == tokName && (tok.val == "true" || tok.val == "false" || tok.val == "null"))
+	if literal {
+		if after := p.peekAt(1); after.kind == tokComma || after.kind == tokRParen {
+			return p.parseLiteral()
+		}
+	}
+	return p.parseLogicalExpr()
+}
+
+//检查表达式是否可以作为逻辑值使用
+func (p *parser) checkLogical(e *filterExpr) error {
+	switch e.kind {
+	case "or", "and", "not":
+		for _, arg := range e.args {
+			if err := p.checkLogical(arg); err != nil {
+				return err
+			}
+		}
+	case "literal":
+		return newSyntaxError(p.path, e.pos, "", "literal must be compared")
+	case "func":
+		if filterFuncs[e.fn].result == valueType {
+			return newSyntaxError(p.path, e.pos, e.fn, "result of function '"+e.fn+"' must be compared")
+		}
+	}
+	return nil
+}
+
+//检查表达式是否可以作为t类型的参数或操作数
+func (p *parser) checkArg(e *filterExpr, t exprType) error {
+	switch t {
+	case valueType:
+		switch e.kind {
+		case "literal":
+			return nil
+		case "query":
+			if p.rfc && !isSingularQuery(e.query) {
+				return newSyntaxError(p.path, e.pos, "", "query must be singular")
+			}
+			return nil
+		case "func":
+			if filterFuncs[e.fn].result == valueType {
+				return nil
+			}
+		}
+		return newSyntaxError(p.path, e.pos, "", "expression is not a value")
+	case nodesType:
+		if e.kind == "query" || (e.kind == "func" && filterFuncs[e.fn].result == nodesType) {
+			return nil
+		}
+		return newSyntaxError(p.path, e.pos, "", "expression is not a query")
+	default:
+		return p.checkLogical(e)
+	}
+}
+
+//是否是只包含单个name或index选择器的路径，这种路径最多匹配一个节点
+func isSingularQuery(q *pathNode) bool {
+	for _, seg := range q.segments {
+		if seg.descendant || len(seg.selectors) != 1 {
+			return false
+		}
+		if kind := seg.selectors[0].kind; kind != "name" && kind != "index" {
+			return false
+		}
+	}
+	return true
+}
+
+//计算过滤表达式的逻辑值
+//current 当前节点(@)的值，root 根节点($)的值
+func evalLogical(e *filterExpr, current, root interface{}) bool {
+	switch e.kind {
+	case "or":
+		return evalLogical(e.args[0], current, root) || evalLogical(e.args[1], current, root)
+	case "and":
+		return evalLogical(e.args[0], current, root) && evalLogical(e.args[1], current, root)
+	case "not":
+		return !evalLogical(e.args[0], current, root)
+	case "compare":
+		return compareValues(evalValue(e.args[0], current, root), evalValue(e.args[1], current, root), e.op)
+	case "query":
+		return len(evalQuery(e.query, current, root)) > 0
+	case "func":
+		res := callFunc(e, current, root)
+		if nodes, ok := res.([]*node); ok {
+			return len(nodes) > 0
+		}
+		return res == true
+	}
+	return false
+}
+
+//计算表达式的值，不存在时返回nothing
+func evalValue(e *filterExpr, current, root interface{}) interface{} {
+	switch e.kind {
+	case "literal":
+		return e.value
+	case "query":
+		nodes := evalQuery(e.query, current, root)
+		if len(nodes) == 1 {
+			return nodes[0].value
+		}
+		return nothing
+	case "func":
+		return callFunc(e, current, root)
+	}
+	return nothing
+}
+
+//调用过滤表达式中的函数
+func callFunc(e *filterExpr, current, root interface{}) interface{} {
+	fn := filterFuncs[e.fn]
+	args := make([]interface{}, len(e.args))
+	for i, arg := range e.args {
+		switch fn.params[i] {
+		case valueType:
+			args[i] = evalValue(arg, current, root)
+		case nodesType:
+			if arg.kind == "query" {
+				args[i] = evalQuery(arg.query, current, root)
+			} else {
+				args[i] = callFunc(arg, current, root)
+			}
+		default:
+			args[i] = evalLogical(arg, current, root)
+		}
+	}
+	return fn.call(args)
+}
+
+//length(value): 字符串的字符数，数组的元素个数或对象的成员个数
+func funcLength(args []interface{}) interface{} {
+	switch v := args[0].(type) {
+	case string:
+		return float64(utf8.RuneCountInString(v))
+	}
+	if keys, ok := objectKeys(args[0]); ok {
+		return float64(len(keys))
+	}
+	if length, ok := arrayLen(args[0]); ok {
+		return float64(length)
+	}
+	return nothing
+}
+
+//count(nodes): 节点个数
+func funcCount(args []interface{}) interface{} {
+	return float64(len(args[0].([]*node)))
+}
+
+//match(value, pattern): 整个字符串是否匹配I-Regexp
+func funcMatch(args []interface{}) interface{} {
+	return matchIRegexp(args[0], args[1], true)
+}
+
+//search(value, pattern): 字符串中是否有子串匹配I-Regexp
+func funcSearch(args []interface{}) interface{} {
+	return matchIRegexp(args[0], args[1], false)
+}
+
+//value(nodes): 只有一个节点时返回它的值
+func funcValue(args []interface{}) interface{} {
+	nodes := args[0].([]*node)
+	if len(nodes) == 1 {
+		return nodes[0].value
+	}
+	return nothing
+}
+
+func matchIRegexp(value, pattern interface{}, full bool) bool {
+	s, ok := value.(string)
+	if !ok {
+		return false
+	}
+	p, ok := pattern.(string)
+	if !ok {
+		return false
+	}
+	expr, ok := iregexpToGo(p)
+	if !ok {
+		return false
+	}
+	if full {
+		expr = `^(?:` + expr + `)$`
+	}
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return false
+	}
+	return re.MatchString(s)
+}
+
+//将I-Regexp(RFC 9485)转换为go的正则表达式，不是合法的I-Regexp时ok为false
+//'.'不匹配\n和\r，'^'和'$'没有特殊含义
+func iregexpToGo(pattern string) (string, bool) {
+	var sb strings.Builder
+	inClass := false
+	for i := 0; i < len(pattern); i++ {
+		c := pattern[i]
+		switch {
+		case c == '\\':
+			if i+1 >= len(pattern) {
+				return "", false
+			}
+			next := pattern[i+1]
+			switch {
+			case next == 'p' || next == 'P':
+				end := strings.IndexByte(pattern[i:], '}')
+				if i+2 >= len(pattern) || pattern[i+2] != '{' || end < 0 {
+					return "", false
+				}
+				sb.WriteString(pattern[i : i+end+1])
+				i += end
+				continue
+			case strings.IndexByte(`()*+-.?[\]^{|}nrt`, next) >= 0:
+				sb.WriteString(pattern[i : i+2])
+				i++
+				continue
+			}
+			return "", false
+		case inClass:
+			if c == '[' {
+				return "", false
+			}
+			if c == ']' {
+				inClass = false
+			}
+			sb.WriteByte(c)
+		case c == '[':
+			inClass = true
+			sb.WriteByte(c)
+			if i+1 < len(pattern) && pattern[i+1] == '^' {
+				sb.WriteByte('^')
+				i++
+			}
+			//紧跟的']'是字符本身
+			if i+1 < len(pattern) && pattern[i+1] == ']' {
+				return "", false
+			}
+		case c == '.':
+			sb.WriteString(`[^\n\r]`)
+		case c == '^' || c == '$':
+			sb.WriteByte('\\')
+			sb.WriteByte(c)
+		case c == '(' && i+1 < len(pattern) && pattern[i+1] == '?':
+			return "", false
+		default:
+			sb.WriteByte(c)
+		}
+	}
+	if inClass {
+		return "", false
+	}
+	return sb.String(), true
+}
@@ -0,0 +1,80 @@
+package jsonpath
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"hash"
+)
+
+//摘要的编码方式
+type HashEncoding int
+
+const (
+	//小写的十六进制，默认的编码方式
+	HashHex HashEncoding = iota
+	//带填充的标准base64
+	HashBase64
+	//不带填充的URL安全base64
+	HashBase64URL
+)
+
+//把匹配到的值替换成加盐的摘要，相同的配置下同一个值总是得到同一个摘要，不能还原
+//Key 不为空时计算HMAC-SHA256(Key, msg)，否则计算SHA-256(msg)
+//msg为8字节大端序的Salt长度+Salt+value，盐和值的分界是确定的，盐"ab"+"c"与盐"a"+"bc"得到不同的摘要
+//Length 大于0时只保留编码后的前Length个字符
+type HashOperator struct {
+	Salt     []byte
+	Key      []byte
+	Encoding HashEncoding
+	Length   int
+	//值不是字符串时的处理方式，默认把数字转换成字符串后计算摘要，NonStringSkip时保持原值
+	NonString NonStringMode
+}
+
+func (o HashOperator) Apply(parent interface{}, key interface{}, value interface{}) (interface{}, bool, error) {
+	if o.Length < 0 {
+		return nil, false, fmt.Errorf("hash length should not be negative: %d", o.Length)
+	}
+	if o.Encoding < HashHex || o.Encoding > HashBase64URL {
+		return nil, false, fmt.Errorf("unknown hash encoding: %d", o.Encoding)
+	}
+	str, ok, err := string_operand(nonstring_mode(o.NonString, NonStringStringify), key, value)
+	if !ok || err != nil {
+		return value, false, err
+	}
+	var h hash.Hash
+	if len(o.Key) > 0 {
+		h = hmac.New(sha256.New, o.Key)
+	} else {
+		h = sha256.New()
+	}
+	var saltLen [8]byte
+	binary.BigEndian.PutUint64(saltLen[:], uint64(len(o.Salt)))
+	h.Write(saltLen[:])
+	h.Write(o.Salt)
+	h.Write([]byte(str))
+	sum := h.Sum(nil)
+
+	var res string
+	switch o.Encoding {
+	case HashBase64:
+		res = base64.StdEncoding.EncodeToString(sum)
+	case HashBase64URL:
+		res = base64.RawURLEncoding.EncodeToString(sum)
+	default:
+		res = hex.EncodeToString(sum)
+	}
+	if o.Length > 0 && o.Length < len(res) {
+		res = res[:o.Length]
+	}
+	return res, false, nil
+}
+
+//向外暴露通过jsonpath把数据替换成加盐SHA-256摘要(十六进制)的接口，数字转换成字符串后计算摘要
+func JsonPathLookUpAndHash(obj interface{}, jpath string, salt []byte) (interface{}, error) {
+	return JsonPathLookUpAndApply(obj, jpath, HashOperator{Salt: salt})
+}
@@ -0,0 +1,99 @@
+package jsonpath
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func Test_jsonpath_hash_operator(t *testing.T) {
+	//期望的摘要由独立的实现计算: sha256(8字节大端序的盐长度 + 盐 + 值)
+	//sha256("\x00\x00\x00\x00\x00\x00\x00\x01" + "a" + "bc")
+	const abc = "e98bc483cc6af9ccf82bc23e481a128c12818251d9d359a7b560dcabfd7909d6"
+	tcases := []struct {
+		Op     HashOperator
+		Value  interface{}
+		Expect interface{}
+	}{
+		{HashOperator{Salt: []byte("a")}, "bc", abc},
+		//盐和值的分界不同时摘要不同
+		{HashOperator{Salt: []byte("ab")}, "c", "290a3daa9b49526a2ed7352cd7f5e69c551a01e15de61fc04c43f780d7172b71"},
+		{HashOperator{}, "abc", "f3652e4ce938bb9965f62c3ca4d8f69301a6c85b1e86eac67e291152d3c0e3dd"},
+		{HashOperator{Salt: []byte("a"), Length: 8}, "bc", abc[:8]},
+		{HashOperator{Salt: []byte("a"), Length: 100}, "bc", abc},
+		{HashOperator{Salt: []byte("a"), Encoding: HashBase64}, "bc", "6YvEg8xq+cz4K8I+SBoSjBKBglHZ01mntWDcq/15CdY="},
+		{HashOperator{Salt: []byte("a"), Encoding: HashBase64URL}, "bc", "6YvEg8xq-cz4K8I-SBoSjBKBglHZ01mntWDcq_15CdY"},
+		//HMAC-SHA256("key", "\x00\x00\x00\x00\x00\x00\x00\x0a" + "The quick " + "brown fox jumps over the lazy dog")
+		{HashOperator{Key: []byte("key"), Salt: []byte("The quick ")}, "brown fox jumps over the lazy dog", "8c37c3f612c77ff0eec9db3d87e8efa09027390149fe1e00939b43b7569e703f"},
+		//数字默认转换成字符串后计算摘要，sha256(8字节的0 + "12.5")
+		{HashOperator{}, 12.5, "8756349dd3435cc1ea4e9178a263bf14a94c7baeb84efe5f8094690ece497c71"},
+		{HashOperator{NonString: NonStringSkip}, 12.5, 12.5},
+		{HashOperator{}, true, true},
+		{HashOperator{NonString: NonStringStringify, Salt: []byte("ab")}, json.Number("12"), "774d5a3a2dd5c892488ecae4623297f58dfa81ec9e1d921c5a7fad9feff60c5e"},
+		{HashOperator{NonString: NonStringStringify}, nil, nil},
+	}
+	for idx, tcase := range tcases {
+		res, remove, err := tcase.Op.Apply(nil, "v", tcase.Value)
+		if err != nil || remove || res != tcase.Expect {
+			t.Errorf("idx: %d, value: %v, (got)%v != (exp)%v, err: %v", idx, tcase.Value, res, tcase.Expect, err)
+		}
+	}
+
+	for idx, op := range []HashOperator{{Length: -1}, {Encoding: 10}, {NonString: NonStringError}} {
+		if _, _, err := op.Apply(nil, "v", 1.0); err == nil {
+			t.Errorf("idx: %d, op: %v, error not raised", idx, op)
+		}
+	}
+}
+
+func Test_jsonpath_lookup_and_hash(t *testing.T) {
+	doc := `{"uid": "u1", "ids": ["u1", "u2", "u3"], "logs": [{"uid": "u2", "level": "error"}, {"uid": "u3", "level": "info"}], "nested": {"uid": "u4"}}`
+	op := HashOperator{Salt: []byte("salt"), Length: 12}
+	//sha256(8字节大端序的盐长度 + "salt" + 值)的前12个字符
+	hashes := map[string]string{"u1": "bc6fd00cb01d", "u2": "465075e099b7", "u3": "304a28bce700", "u4": "8221be58f82c"}
+	hash := func(v string) string { return hashes[v] }
+	tcases := []struct {
+		Path   string
+		Expect string
+	}{
+		{"$.uid", `{"uid": "` + hash("u1") + `", "ids": ["u1", "u2", "u3"], "logs": [{"uid": "u2", "level": "error"}, {"uid": "u3", "level": "info"}], "nested": {"uid": "u4"}}`},
+		{"$.ids[0]", `{"uid": "u1", "ids": ["` + hash("u1") + `", "u2", "u3"], "logs": [{"uid": "u2", "level": "error"}, {"uid": "u3", "level": "info"}], "nested": {"uid": "u4"}}`},
+		{"$.ids[1:2]", `{"uid": "u1", "ids": ["u1", "` + hash("u2") + `", "` + hash("u3") + `"], "logs": [{"uid": "u2", "level": "error"}, {"uid": "u3", "level": "info"}], "nested": {"uid": "u4"}}`},
+		{"$.logs[?(@.level == 'error')].uid", `{"uid": "u1", "ids": ["u1", "u2", "u3"], "logs": [{"uid": "` + hash("u2") + `", "level": "error"}, {"uid": "u3", "level": "info"}], "nested": {"uid": "u4"}}`},
+		{"$..uid", `{"uid": "` + hash("u1") + `", "ids": ["u1", "u2", "u3"], "logs": [{"uid": "` + hash("u2") + `", "level": "error"}, {"uid": "` + hash("u3") + `", "level": "info"}], "nested": {"uid": "` + hash("u4") + `"}}`},
+	}
+	for idx, tcase := range tcases {
+		var j, expect interface{}
+		json.Unmarshal([]byte(doc), &j)
+		json.Unmarshal([]byte(tcase.Expect), &expect)
+		res, err := JsonPathLookUpAndApply(j, tcase.Path, op)
+		if err != nil || !reflect.DeepEqual(res, expect) {
+			t.Errorf("idx: %d, path: %s, (got)%v != (exp)%v, err: %v", idx, tcase.Path, toString(res), tcase.Expect, err)
+		}
+	}
+
+	var j interface{}
+	json.Unmarshal([]byte(doc), &j)
+	if _, err := JsonPathLookUpAndHash(j, "$.uid", []byte("a")); err != nil {
+		t.Fatal(err)
+	}
+	if res, _ := JsonPathLookUp(j, "$.uid"); res != "9dad49b08db050f36e927d3c1af04f95cf18298f1607f7439735479feb446679" {
+		t.Errorf("unexpected hash: %v", res)
+	}
+}
+
+//数字形式的user_id同样被替换成摘要，不会以明文保留
+func Test_jsonpath_lookup_and_hash_number(t *testing.T) {
+	var j, expect interface{}
+	json.Unmarshal([]byte(`{"logs": [{"user_id": 10001}, {"user_id": "u2"}, {"user_id": 13800138000}, {"user_id": null}]}`), &j)
+	//sha256(8字节大端序的盐长度 + "salt" + 值)
+	json.Unmarshal([]byte(`{"logs": [
+		{"user_id": "a50b7220587f55a9738024ab878460d791ef9bd90332476f99077047d235ccdb"},
+		{"user_id": "465075e099b7239368beb38b32f192265e42ad289c26f15b3907c8c3ba3ec07c"},
+		{"user_id": "cca61d4e8b3db10a9420a10ff14638b32ed3b4fb2e70899ecac06ab1c9177d9d"},
+		{"user_id": null}]}`), &expect)
+	res, err := JsonPathLookUpAndHash(j, "$.logs[*].user_id", []byte("salt"))
+	if err != nil || !reflect.DeepEqual(res, expect) {
+		t.Errorf("(got)%v != (exp)%v, err: %v", toString(res), toString(expect), err)
+	}
+}
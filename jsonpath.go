@@ -3,9 +3,6 @@ package jsonpath
 import (
 	"errors"
 	"fmt"
-	"git.xiaojukeji.com/ihap/ihap-auth-sdk/conf"
-	"go/token"
-	"go/types"
 	"reflect"
 	"regexp"
 	"strconv"
@@ -15,8 +12,8 @@ import (
 //获取nil object错误模板
 var ErrGetFromNullObj = errors.New("get attribute from null object")
 
-//用作标记递归列过滤和脱敏的全局变量
-var curr = 0
+//LookupOne的结果不是正好一个值时返回的错误
+var ErrNotSingleValue = errors.New("result is not a single value")
 
 //脱敏模板函数类型
 type HandlerDesensitization func(jsonMap map[string]interface{}, key string) error
@@ -30,37 +27,90 @@ const INT_MAX = int(^uint(0) >> 1)
 
 //path 输入的jsonpath字符串
 //steps 解析jsonpath后,操作json的具体步骤
+//query CompileRFC9535解析出的语法树，不为nil时按RFC 9535的语义执行
+//coerce 过滤表达式比较时是否把数字形式的字符串当作数字
+//nodelist Lookup是否总是返回节点列表
+//strict 在数组上取键值时是否要求每个元素都有该键值
+//create Set和Update是否创建缺少的中间对象和数组
 type Compiled struct {
-	path  string
-	steps []step
+	path     string
+	steps    []step
+	query    *pathNode
+	coerce   bool
+	nodelist bool
+	strict   bool
+	create   bool
+	//脱敏时遇到不是字符串的值的处理方式
+	nonString NonStringMode
+}
+
+//Compile的可选配置
+type Option func(*Compiled)
+
+//过滤表达式比较时把数字形式的字符串当作数字，例如 "10" > 9 和 "1" == 1 成立
+//默认不做类型转换，不同类型的值不相等
+func WithNumericStringCoercion() Option {
+	return func(c *Compiled) {
+		c.coerce = true
+	}
+}
+
+//Lookup总是返回节点列表([]interface{})，只选中一个值的路径(例如 $.a.b)也返回只有一个元素的列表
+//出错的情况与默认模式相同，需要单个值时使用LookupOne
+func WithNodeList() Option {
+	return func(c *Compiled) {
+		c.nodelist = true
+	}
+}
+
+//在数组或多个结果组成的列表上取键值时，有元素没有该键值就返回错误，而不是忽略这个元素
+//例如 $.book[*].isbn 在有书没有isbn时出错，Lookup、LookupNodes和LookupAndOperate都会检查
+func WithStrictKeys() Option {
+	return func(c *Compiled) {
+		c.strict = true
+	}
+}
+
+//Set和Update时创建路径中缺少的键值和下标，例如对 {} 执行 $.a.b[1] 得到 {"a":{"b":[null,value]}}
+//键值后面是下标时创建数组，否则创建对象；值为null的中间节点同样会被替换
+//范围和通配符不会创建元素，作用在不存在的值上时返回错误，数据不被修改
+func WithCreateMissing() Option {
+	return func(c *Compiled) {
+		c.create = true
+	}
+}
+
+//LookupAndOperate脱敏时遇到数字、null等不是字符串的值的处理方式，默认保持原值
+//例如NonStringStringify时存成数字的手机号13800138000会脱敏成"138****8000"
+func WithNonStringMask(mode NonStringMode) Option {
+	return func(c *Compiled) {
+		c.nonString = mode
+	}
 }
 
 //操作的单个步骤
-//op 具体操作符(必须，有:root,key,idx,range,scan)
+//op 具体操作符(必须，有:root,key,idx,range,wildcard,union,filter,scan)
 //key 如果步骤中有键值则保存键值
-//args 参数列表，用作保存参数，主要用于idx和range操作
+//args 参数列表，用作保存参数，主要用于idx、range和union操作
+//     scan没有key时args为在每个后代节点上执行的步骤，例如'..*'、'..[0]'
 type step struct {
 	op   string
 	key  string
 	args interface{}
 }
 
-//具体脱敏方式结构体
-//displayChar 脱敏字符
-//start 脱敏开始位置
-//size 脱敏结束位置
-type desensitization struct {
-	displayChar string
-	start       int
-	size        int
-}
-
 func init() {
 	//初始化当前支持的脱敏函数map
-	DesensitizationFuncs[conf.CarNumberDesensitization] = carNumberDesensitization
-	DesensitizationFuncs[conf.PhoneDesensitization] = phoneDesensitization
-	DesensitizationFuncs[conf.IdCardNumberDesensitization] = idCardNumberDesensitization
-	DesensitizationFuncs[conf.NameDesensitization] = nameDesensitization
+	DesensitizationFuncs[CarNumberDesensitization] = carNumberDesensitization
+	DesensitizationFuncs[EmailDesensitization] = emailDesensitization
+	DesensitizationFuncs[BankCardDesensitization] = bankCardDesensitization
+	DesensitizationFuncs[IPDesensitization] = ipDesensitization
+	DesensitizationFuncs[TextPhoneDesensitization] = textPhoneDesensitization
+	for _, strategy := range []*MaskStrategy{phoneStrategy, idCardNumberStrategy, nameStrategy, addressStrategy, passportStrategy} {
+		if err := RegisterMaskStrategy(strategy); err != nil {
+			panic(err)
+		}
+	}
 }
 
 //向外暴露通过jsonpath的查询接口
@@ -80,7 +130,16 @@ func JsonPathLookUpAndDel(obj interface{}, jpath string) (interface{}, error) {
 	if err != nil {
 		return nil, err
 	}
-	return c.LookupAndOperate(obj, conf.DataFieldControl, "")
+	return c.LookupAndOperate(obj, DataFieldControl, "")
+}
+
+//向外暴露通过jsonpath对数据执行自定义操作的接口
+func JsonPathLookUpAndApply(obj interface{}, jpath string, op Operator) (interface{}, error) {
+	c, err := Compile(jpath)
+	if err != nil {
+		return nil, err
+	}
+	return c.LookupAndApply(obj, op)
 }
 
 //向外暴露通过jsonpath的数据脱敏接口
@@ -89,11 +148,11 @@ func JsonPathLookUpAndDesensitization(obj interface{}, jpath string, opertFunc s
 	if err != nil {
 		return nil, err
 	}
-	return c.LookupAndOperate(obj, conf.DataDesensitizationControl, opertFunc)
+	return c.LookupAndOperate(obj, DataDesensitizationControl, opertFunc)
 }
 
-func MustCompile(jpath string) *Compiled {
-	c, err := Compile(jpath)
+func MustCompile(jpath string, opts ...Option) *Compiled {
+	c, err := Compile(jpath, opts...)
 	if err != nil {
 		panic(err)
 	}
@@ -101,206 +160,50 @@ func MustCompile(jpath string) *Compiled {
 }
 
 //解析jsonpath，返回Compiled结构
-func Compile(jpath string) (*Compiled, error) {
-	//tokens 分词后的结果数组
-	tokens, err := tokenize(jpath)
+//opts 可选配置，例如 WithNumericStringCoercion()
+func Compile(jpath string, opts ...Option) (*Compiled, error) {
+	//先解析成语法树，再转换成具体的操作步骤
+	node, err := parsePath(jpath)
 	if err != nil {
 		return nil, err
 	}
-	if tokens[0] != "@" && tokens[0] != "$" {
-		return nil, fmt.Errorf("$ or @ should in front of path")
+	steps, err := buildSteps(node)
+	if err != nil {
+		return nil, err
 	}
-	tokens = tokens[1:]
-	//保存steps的操作数组
-	res := Compiled{
+	c := &Compiled{
 		path:  jpath,
-		steps: []step{},
-	}
-	tokenLen := len(tokens)
-	for i := 0; i < tokenLen; i++ {
-		//如果是递归操作直接获取下一个token做key保存进step
-		if tokens[i] == "*" {
-			i++
-			op, key, args, err := parse_token(tokens[i])
-			if err != nil {
-				return nil, err
-			}
-			// 如果后面出现参数范围限制，则添加相应的args
-			if op == "range" || op == "idx" {
-				res.steps = append(res.steps, step{"scan", key, args})
-			} else {
-				res.steps = append(res.steps, step{"scan", key, nil})
-			}
-		} else {
-			op, key, args, err := parse_token(tokens[i])
-			if err != nil {
-				return nil, err
-			}
-			res.steps = append(res.steps, step{op, key, args})
-		}
+		steps: steps,
 	}
-	return &res, nil
-}
-
-//query,传入的jsonpath字符串
-//将jsonpath进行分词，返回分词后的结果,
-// 过滤：'[',']','.'操作符并解析成相应的操作
-func tokenize(query string) ([]string, error) {
-	tokens := []string{}
-	//	token_start := false
-	//	token_end := false
-	//临时保存当前字符串的变量
-	token := ""
-
-	// fmt.Println("-------------------------------------------------- start")
-	for idx, x := range query {
-		//更新当前字符串流
-		token += string(x)
-		//可打印每一步解析结果信息
-		//fmt.Printf("idx: %d, x: %s, token: %s, tokens: %v\n", idx, string(x), token, tokens)
-		//第一位必须是'$'或容错使用'@'
-		if idx == 0 {
-			if token == "$" || token == "@" {
-				tokens = append(tokens, token[:])
-				token = ""
-				continue
-			} else {
-				return nil, fmt.Errorf("should start with '$'")
-			}
-		}
-		if token == "." {
-			continue
-		} else if token == ".." {
-			//如果是'..'递归操作符，保存这个操作
-			if tokens[len(tokens)-1] != "*" {
-				tokens = append(tokens, "*")
-			}
-			token = "."
-			continue
-		} else {
-			// fmt.Println("else: ", string(x), token)
-			// 操作[]操作符
-			if strings.Contains(token, "[") {
-				// fmt.Println(" contains [ ")
-				if x == ']' && !strings.HasSuffix(token, "\\]") {
-					if token[0] == '.' {
-						tokens = append(tokens, token[1:])
-					} else {
-						tokens = append(tokens, token[:])
-					}
-					token = ""
-					continue
-				}
-			} else {
-				// fmt.Println(" doesn't contains [ ")
-				if x == '.' {
-					if token[0] == '.' {
-						tokens = append(tokens, token[1:len(token)-1])
-					} else {
-						tokens = append(tokens, token[:len(token)-1])
-					}
-					token = "."
-					continue
-				}
-			}
-		}
+	for _, opt := range opts {
+		opt(c)
 	}
-	if len(token) > 0 {
-		if token[0] == '.' {
-			token = token[1:]
-			if token != "*" {
-				tokens = append(tokens, token[:])
-			} else if tokens[len(tokens)-1] != "*" {
-				tokens = append(tokens, token[:])
-			}
-		} else {
-			if token != "*" {
-				tokens = append(tokens, token[:])
-			} else if tokens[len(tokens)-1] != "*" {
-				tokens = append(tokens, token[:])
-			}
-		}
+	if c.coerce {
+		walk_filters(c.steps, func(e *filterExpr) {
+			e.coerce = true
+		})
 	}
-	// fmt.Println("finished tokens: ", tokens)
-	// fmt.Println("================================================= done ")
-	return tokens, nil
+	return c, nil
 }
 
-/*
- op: "root", "key", "idx", "range", "filter", "scan"
- 通过分词数组中的单个分词，解析出相应的操作
-*/
-func parse_token(token string) (op string, key string, args interface{}, err error) {
-	if token == "$" {
-		return "root", "$", nil, nil
-	}
-
-	bracket_idx := strings.Index(token, "[")
-	if bracket_idx < 0 {
-		//如不包含'['则当做map的key处理
-		return "key", token, nil, nil
-	} else {
-		//包含的话解析出key和中括号中的数据
-		key = token[:bracket_idx]
-		tail := token[bracket_idx:]
-		if len(tail) < 3 {
-			err = fmt.Errorf("len(tail) should >=3, %v", tail)
-			return
+//对steps中所有的过滤表达式节点执行fn，包括过滤表达式中路径里嵌套的过滤表达式
+func walk_filters(steps []step, fn func(e *filterExpr)) {
+	var walk func(e *filterExpr)
+	walk = func(e *filterExpr) {
+		fn(e)
+		for _, arg := range e.args {
+			walk(arg)
 		}
-		tail = tail[1 : len(tail)-1]
-
-		//fmt.Println(key, tail)
-		//解析中括号中的内容，得到正确操作
-		if strings.Contains(tail, "?") {
-			// filter -------------------------------------------------
-			op = "filter"
-			if strings.HasPrefix(tail, "?(") && strings.HasSuffix(tail, ")") {
-				args = strings.Trim(tail[2:len(tail)-1], " ")
-			}
-			return
-		} else if strings.Contains(tail, ":") {
-			// range ----------------------------------------------
-			op = "range"
-			tails := strings.Split(tail, ":")
-			if len(tails) != 2 {
-				err = fmt.Errorf("only support one range(from, to): %v", tails)
-				return
-			}
-			var frm interface{}
-			var to interface{}
-			if frm, err = strconv.Atoi(strings.Trim(tails[0], " ")); err != nil {
-				if strings.Trim(tails[0], " ") == "" {
-					err = nil
-				}
-				frm = nil
-			}
-			if to, err = strconv.Atoi(strings.Trim(tails[1], " ")); err != nil {
-				if strings.Trim(tails[1], " ") == "" {
-					err = nil
-				}
-				to = nil
-			}
-			args = [2]interface{}{frm, to}
-			return
-		} else if tail == "*" {
-			op = "range"
-			args = [2]interface{}{nil, nil}
-			return
-		} else {
-			// idx ------------------------------------------------
-			op = "idx"
-			res := []int{}
-			for _, x := range strings.Split(tail, ",") {
-				if i, err := strconv.Atoi(strings.Trim(x, " ")); err == nil {
-					res = append(res, i)
-				} else {
-					return "", "", nil, err
-				}
-			}
-			args = res
+		walk_filters(e.steps, fn)
+	}
+	for _, s := range steps {
+		switch args := s.args.(type) {
+		case *filterExpr:
+			walk(args)
+		case step:
+			walk_filters([]step{args}, fn)
 		}
 	}
-	return op, key, args, nil
 }
 
 func (c *Compiled) String() string {
@@ -310,15 +213,34 @@ func (c *Compiled) String() string {
 //查找的实际操作接口
 //obj 需要处理的json的字节数组
 func (c *Compiled) Lookup(obj interface{}) (interface{}, error) {
+	if c.query != nil {
+		return c.lookupRFC9535(obj), nil
+	}
+	if c.nodelist || c.strict {
+		nodes, err := c.lookup_nodes(obj, false)
+		if err != nil {
+			return nil, err
+		}
+		if c.nodelist {
+			res := make([]interface{}, len(nodes))
+			for i, n := range nodes {
+				res[i] = n.value
+			}
+			return res, nil
+		}
+	}
 	var err error
 	var root = obj
+	//当前结果是否是上一步得到的多个值组成的列表
+	var multi bool
 	//遍历所有操作一步步进行
 	for _, s := range c.steps {
+		var in = obj
 		// "key", "idx"
 		switch s.op {
 		//map的键值操作
 		case "key":
-			obj, err = get_key(obj, s.key)
+			obj, err = get_key_multi(obj, s.key, multi)
 			if err != nil {
 				return nil, err
 			}
@@ -326,7 +248,7 @@ func (c *Compiled) Lookup(obj interface{}) (interface{}, error) {
 		case "idx":
 			if len(s.key) > 0 {
 				// no key `$[0].test`
-				obj, err = get_key(obj, s.key)
+				obj, err = get_key_multi(obj, s.key, multi)
 				if err != nil {
 					return nil, err
 				}
@@ -359,147 +281,61 @@ func (c *Compiled) Lookup(obj interface{}) (interface{}, error) {
 			//有key，先通过key拿到值之后再筛选范围
 			if len(s.key) > 0 {
 				// no key `$[:1].test`
-				obj, err = get_key(obj, s.key)
+				obj, err = get_key_multi(obj, s.key, multi)
 				if err != nil {
 					return nil, err
 				}
 			}
-			if argsv, ok := s.args.([2]interface{}); ok == true {
-				obj, err = get_range(obj, argsv[0], argsv[1])
+			if argsv, ok := s.args.([3]interface{}); ok == true {
+				obj, err = get_range(obj, argsv[0], argsv[1], argsv[2])
 				if err != nil {
 					return nil, err
 				}
 			} else {
-				return nil, fmt.Errorf("range args length should be 2")
-			}
-		//操作符过滤
-		case "filter":
-			obj, err = get_key(obj, s.key)
-			if err != nil {
-				return nil, err
-			}
-			obj, err = get_filtered(obj, root, s.args.(string))
-			if err != nil {
-				return nil, err
-			}
-		//通过递归操作在数据中取得所有数据
-		case "scan":
-			obj, err = get_recursion(obj, s.key, s.args)
-			if err != nil {
-				return nil, err
+				return nil, fmt.Errorf("range args length should be 3")
 			}
-		default:
-			return nil, fmt.Errorf("expression don't support in filter")
-		}
-	}
-	return obj, nil
-}
-
-//数据列过滤和数据脱敏在这个函数集中处理
-//mode用于分辨操作模式
-//opertFunc 只对数据托名有作用。用于选择数据脱敏模式
-func (c *Compiled) LookupAndOperate(obj interface{}, mode string, opertFunc string) (interface{}, error) {
-	var err error
-	var temp = obj
-	var root = obj
-	var stepLen = len(c.steps)
-	var lastStep = stepLen - 1
-	for i, s := range c.steps {
-		// "key", "idx"
-
-		switch s.op {
-		case "key":
-			if i == lastStep {
-				err = operate_key(temp, s.key, mode, opertFunc)
-			} else {
-				temp, err = get_key(temp, s.key)
+		//通配符获取所有成员
+		case "wildcard":
+			if len(s.key) > 0 {
+				obj, err = get_key_multi(obj, s.key, multi)
+				if err != nil {
+					return nil, err
+				}
 			}
+			obj, err = get_wildcard(obj, multi || isListStep(step{"key", s.key, nil}, in))
 			if err != nil {
 				return nil, err
 			}
-		case "idx":
-			if i == lastStep {
-				err = operate_idx(temp, s.key, s.args, mode, opertFunc)
+		//中括号中多个键值和下标的组合
+		case "union":
+			if len(s.key) > 0 {
+				obj, err = get_key_multi(obj, s.key, multi)
 				if err != nil {
 					return nil, err
 				}
-			} else {
-				if len(s.key) > 0 {
-					// no key `$[0].test`
-					temp, err = get_key(temp, s.key)
-					if err != nil {
-						return nil, err
-					}
-				}
-
-				if len(s.args.([]int)) > 1 {
-					res := []interface{}{}
-					for _, x := range s.args.([]int) {
-						//fmt.Println("idx ---- ", x)
-						tmp, err := get_idx(temp, x)
-						if err != nil {
-							return nil, err
-						}
-						res = append(res, tmp)
-					}
-					temp = res
-				} else if len(s.args.([]int)) == 1 {
-					//fmt.Println("idx ----------------3")
-					temp, err = get_idx(temp, s.args.([]int)[0])
-					if err != nil {
-						return nil, err
-					}
-				} else {
-					//fmt.Println("idx ----------------4")
-					return nil, fmt.Errorf("cannot index on empty slice")
-				}
 			}
-		case "range":
-			if i == lastStep {
-				err = operate_range(temp, s.key, s.args, mode, opertFunc)
-				if err != nil {
-					return nil, err
-				}
-			} else {
-				if len(s.key) > 0 {
-					// no key `$[:1].test`
-					temp, err = get_key(temp, s.key)
-					if err != nil {
-						return nil, err
-					}
-				}
-				if argsv, ok := s.args.([2]interface{}); ok == true {
-					temp, err = get_range(temp, argsv[0], argsv[1])
-					if err != nil {
-						return nil, err
-					}
-				} else {
-					return nil, fmt.Errorf("range args length should be 2")
-				}
+			obj, err = get_union(obj, s.args.([]interface{}))
+			if err != nil {
+				return nil, err
 			}
+		//操作符过滤
 		case "filter":
-			if i == lastStep {
-				err := operate_filter(temp, root, s.key, s.args.(string), mode, opertFunc)
-				if err != nil {
-					return nil, err
-				}
-			} else {
-				temp, err = get_key(temp, s.key)
-				if err != nil {
-					return nil, err
-				}
-				temp, err = get_filtered(temp, root, s.args.(string))
+			if len(s.key) > 0 {
+				obj, err = get_key_multi(obj, s.key, multi)
 				if err != nil {
 					return nil, err
 				}
 			}
+			obj, err = get_filtered(obj, root, s.args.(*filterExpr), multi || isListStep(step{"key", s.key, nil}, in))
+			if err != nil {
+				return nil, err
+			}
+		//通过递归操作在数据中取得所有数据
 		case "scan":
-			if i == lastStep {
-				//初始化当前已递归遍历在第一个位置
-				curr = 0
-				err = operateRecursion(temp, s.key, s.args, mode, opertFunc)
+			if len(s.key) == 0 {
+				obj, err = get_descendants(obj, root, s.args.(step))
 			} else {
-				temp, err = get_recursion(temp, s.key, s.args)
+				obj, err = get_recursion(obj, s.key, s.args)
 			}
 			if err != nil {
 				return nil, err
@@ -507,82 +343,64 @@ func (c *Compiled) LookupAndOperate(obj interface{}, mode string, opertFunc stri
 		default:
 			return nil, fmt.Errorf("expression don't support in filter")
 		}
+		multi = isListStep(s, in)
 	}
 	return obj, nil
 }
 
-//通过下标修改json对象
-func operate_idx(obj interface{}, key string, args interface{}, mode string, opertFunc string) error {
-	var argvs = args.([]int)
-	if len(key) > 0 {
-		if reflect.TypeOf(obj).Kind() == reflect.Map {
-			if jsonMap, ok := obj.(map[string]interface{}); ok {
-				for k, v := range jsonMap {
-					if k == key {
-						if reflect.TypeOf(v).Kind() != reflect.Slice {
-							return fmt.Errorf("%s object is not slice", key)
-						}
-						var tempv = v.([]interface{})
-						if mode == conf.DataFieldControl {
-							var resultv []interface{}
-							for w, q := range tempv {
-								var isExist bool
-								for _, arg := range argvs {
-									if arg == w {
-										isExist = true
-									}
-								}
-								if isExist == false {
-									resultv = append(resultv, q)
-								}
-							}
-							jsonMap[k] = resultv
-						}
-					}
-				}
-			}
-		} else {
-			return fmt.Errorf("object is not map")
-		}
-	} else {
-		return fmt.Errorf("key is nil")
+//查找只有一个结果的路径，返回这个值
+//没有匹配或匹配到多个值时返回ErrNotSingleValue，路径中的错误与LookupNodes相同
+func (c *Compiled) LookupOne(obj interface{}) (interface{}, error) {
+	nodes, err := c.LookupNodes(obj)
+	if err != nil {
+		return nil, err
 	}
-	return nil
+	if len(nodes) != 1 {
+		return nil, ErrNotSingleValue
+	}
+	return nodes[0].Value, nil
 }
 
-//有两种情况，key为空即obj为目标数组，key不为空obj为map。obj[key]为目标数组
-func operate_range(obj interface{}, key string, args interface{}, mode string, opertFunc string) error {
-	switch reflect.TypeOf(obj).Kind() {
-	case reflect.Slice:
-		length := reflect.ValueOf(obj).Len()
-		argvs := args.([2]interface{})
-		left, right, err := transforRange(length, argvs[0], argvs[1])
-		if err != nil {
-			return err
-		}
-		var tempargs []int
-		for i := left; i <= right; i++ {
-			tempargs = append(tempargs, i)
-		}
-		err = operate_idx(obj, key, tempargs, mode, opertFunc)
-		return err
-	case reflect.Map:
-		tempMap := obj.(map[string]interface{})
-		length := reflect.ValueOf(tempMap[key]).Len()
-		argvs := args.([2]interface{})
-		left, right, err := transforRange(length, argvs[0], argvs[1])
-		if err != nil {
-			return err
-		}
-		var tempargs []int
-		for i := left; i <= right; i++ {
-			tempargs = append(tempargs, i)
-		}
-		err = operate_idx(obj, key, tempargs, mode, opertFunc)
-		return err
-	default:
-		return fmt.Errorf("obj is not slice or map")
+//步骤的结果是否是由多个值组成的列表，而不是json中的数组
+//obj为执行步骤前的对象
+func isListStep(s step, obj interface{}) bool {
+	switch s.op {
+	case "range", "wildcard", "union", "filter", "scan":
+		return true
+	case "idx":
+		return len(s.args.([]int)) > 1
+	case "key":
+		//在数组上取键值会对每个元素分别取值
+		return len(s.key) > 0 && reflect.TypeOf(obj) != nil && reflect.TypeOf(obj).Kind() == reflect.Slice
+	}
+	return false
+}
+
+//数据列过滤和数据脱敏在这个函数集中处理
+//mode用于分辨操作模式，DataFieldControl删除，DataDesensitizationControl脱敏
+//opertFunc 只对数据托名有作用。用于选择数据脱敏模式
+func (c *Compiled) LookupAndOperate(obj interface{}, mode string, opertFunc string) (interface{}, error) {
+	op, err := mode_operator(mode, opertFunc, c.nonString)
+	if err != nil {
+		return nil, err
+	}
+	return c.LookupAndApply(obj, op)
+}
+
+//对所有匹配到的值执行op，op返回remove时删除该值，否则用返回的新值替换
+//匹配的节点与LookupNodes相同，嵌套的匹配项先于外层的匹配项处理，同一个位置只处理一次
+//数组中被删除的元素在所有元素处理完后统一删除；路径为$时返回op处理后的根节点
+func (c *Compiled) LookupAndApply(obj interface{}, op Operator) (interface{}, error) {
+	//先找到所有节点，有错误时不修改数据
+	nodes, err := c.find_nodes(obj)
+	if err != nil {
+		return nil, err
+	}
+	targets := make([]opTarget, len(nodes))
+	for i, n := range nodes {
+		targets[i] = opTarget{n: n, op: op}
 	}
+	return apply_targets(obj, targets)
 }
 
 //递归查找支持函数
@@ -595,11 +413,9 @@ func get_recursion(obj interface{}, key string, args interface{}) (interface{},
 	recursion_search(obj, key, &result)
 	//判断是否之后跟有表示范围的语句
 	if args != nil {
-		var argsv [2]interface{}
 		// 分辨中括号中是单个数字还是范围
-		if tempargsv, ok := args.([2]interface{}); ok == true {
-			argsv = tempargsv
-			tempresult, err := get_range(result, argsv[0], argsv[1])
+		if argsv, ok := args.([3]interface{}); ok == true {
+			tempresult, err := get_range(result, argsv[0], argsv[1], argsv[2])
 			if err != nil {
 				return nil, err
 			}
@@ -616,7 +432,7 @@ func get_recursion(obj interface{}, key string, args interface{}) (interface{},
 			}
 			return tempresult, nil
 		} else {
-			return nil, fmt.Errorf("range args length should be 2 or 1")
+			return nil, fmt.Errorf("range args length should be 3 or 1")
 		}
 	} else {
 		return result, nil
@@ -624,17 +440,21 @@ func get_recursion(obj interface{}, key string, args interface{}) (interface{},
 }
 
 //递归查找需要调用，支持'..'操作符
+//按文档顺序返回匹配项：先是当前对象的key，再按键值排序依次查找每个成员，与RFC 9535的顺序一致
 func recursion_search(obj interface{}, key string, res *[]interface{}) {
+	if reflect.TypeOf(obj) == nil {
+		return
+	}
 	switch reflect.TypeOf(obj).Kind() {
 	case reflect.Map:
-		if jsonMap, ok := obj.(map[string]interface{}); ok {
-			for k, v := range jsonMap {
-				if k == key {
-					*res = append(*res, v)
-				} else {
-					recursion_search(v, key, res)
-				}
-			}
+		if v, ok := objectGet(obj, key); ok {
+			*res = append(*res, v)
+		}
+		keys, _ := objectKeys(obj)
+		for _, k := range keys {
+			//匹配项中嵌套的匹配项也需要返回
+			v, _ := objectGet(obj, k)
+			recursion_search(v, key, res)
 		}
 	case reflect.Slice:
 		for i := 0; i < reflect.ValueOf(obj).Len(); i++ {
@@ -644,167 +464,104 @@ func recursion_search(obj interface{}, key string, res *[]interface{}) {
 	}
 }
 
-//递归操作需要调用，具体调用模式由mode区分
-func operateRecursion(obj interface{}, key string, args interface{}, mode string, opertFunc string) error {
-	if reflect.TypeOf(obj) == nil {
-		return ErrGetFromNullObj
-	}
-	var err error
-	//判断是否之后跟有表示范围的语句
-	if args != nil {
-		var argsv [2]int
-		// 分辨中括号中是单个数字还是范围
-		if tempargsv, ok := args.([2]interface{}); ok == true {
-			argsv[0] = tempargsv[0].(int)
-			argsv[1] = tempargsv[1].(int)
-			if mode == conf.DataDesensitizationControl {
-				err = recursion_desensitization(obj, key, argsv[0], argsv[1], opertFunc)
-			} else if mode == conf.DataFieldControl {
-				recursion_del(obj, key, argsv[0], argsv[1])
-			}
-		} else if tempargsv, ok := args.([]int); ok == true {
-			for _, v := range tempargsv {
-				if mode == conf.DataDesensitizationControl {
-					err = recursion_desensitization(obj, key, v, v, opertFunc)
-				} else if mode == conf.DataFieldControl {
-					recursion_del(obj, key, v, v)
-				}
-			}
-		} else {
-			return fmt.Errorf("range args length should be 2 or 1")
+//按文档顺序遍历obj和它的所有后代节点，对象的成员按键值排序
+//parent为节点的父节点，key为节点在父节点中的键值(string)或下标(int)，根节点都为nil
+func recursion_walk(obj, parent, key interface{}, fn func(obj, parent, key interface{})) {
+	fn(obj, parent, key)
+	if keys, ok := objectKeys(obj); ok {
+		for _, k := range keys {
+			v, _ := objectGet(obj, k)
+			recursion_walk(v, obj, k, fn)
 		}
-	} else {
-		if mode == conf.DataDesensitizationControl {
-			err = recursion_desensitization(obj, key, 0, INT_MAX, opertFunc)
-		} else if mode == conf.DataFieldControl {
-			recursion_del(obj, key, 0, INT_MAX)
+	} else if length, ok := arrayLen(obj); ok {
+		for i := 0; i < length; i++ {
+			recursion_walk(arrayGet(obj, i), obj, i, fn)
 		}
 	}
-	if err != nil {
-		return err
-	} else {
-		return nil
-	}
 }
 
-//递归脱敏
-func recursion_desensitization(obj interface{}, key string, left int, right int, opertFunc string) error {
-	if curr > right {
-		return nil
+//'..'后的选择器s在单个节点obj上选中的子节点，返回键值(string)或下标(int)
+//选择器不适用于该节点时返回空
+func select_keys(obj, root interface{}, s step) []interface{} {
+	var res []interface{}
+	keys, isMap := objectKeys(obj)
+	length, isSlice := arrayLen(obj)
+	//负数下标从数组末尾开始计算，越界的下标忽略
+	appendIdx := func(i int) {
+		if i < 0 {
+			i += length
+		}
+		if i >= 0 && i < length {
+			res = append(res, i)
+		}
 	}
-	switch reflect.TypeOf(obj).Kind() {
-	case reflect.Map:
-		if jsonMap, ok := obj.(map[string]interface{}); ok {
-			for k, v := range jsonMap {
-				if k == key {
-					if curr < left {
-						curr++
-					} else if curr <= right {
-						curr++
-						var err error
-						if desensitFunc, ok := DesensitizationFuncs[opertFunc]; ok {
-							err = desensitFunc(jsonMap, key)
-						} else {
-							return fmt.Errorf("%s not found in function map", opertFunc)
-						}
-						if err != nil {
-							return err
-						}
-						continue
-					} else {
-						break
-					}
-				} else {
-					err := recursion_desensitization(v, key, left, right, opertFunc)
-					if err != nil {
-						return err
-					}
-				}
+	switch s.op {
+	case "wildcard":
+		for _, k := range keys {
+			res = append(res, k)
+		}
+		for i := 0; isSlice && i < length; i++ {
+			res = append(res, i)
+		}
+	case "idx":
+		for _, i := range s.args.([]int) {
+			if isSlice {
+				appendIdx(i)
 			}
 		}
-	case reflect.Slice:
-		for i := 0; i < reflect.ValueOf(obj).Len(); i++ {
-			tmp, _ := get_idx(obj, i)
-			err := recursion_desensitization(tmp, key, left, right, opertFunc)
-			if err != nil {
-				return err
+	case "range":
+		if isSlice {
+			argsv := s.args.([3]interface{})
+			for _, i := range rangeIndices(length, argsv[0], argsv[1], argsv[2]) {
+				res = append(res, i)
 			}
 		}
-	}
-	return nil
-}
-
-//递归列过滤
-func recursion_del(obj interface{}, key string, left int, right int) {
-	if curr > right {
-		return
-	}
-	switch reflect.TypeOf(obj).Kind() {
-	case reflect.Map:
-		if jsonMap, ok := obj.(map[string]interface{}); ok {
-			for k, v := range jsonMap {
-				if k == key {
-					if curr < left {
-						curr++
-					} else if curr <= right {
-						curr++
-						delete(jsonMap, k)
-					} else {
-						break
-					}
-				} else {
-					recursion_del(v, key, left, right)
+	case "union":
+		for _, arg := range s.args.([]interface{}) {
+			switch v := arg.(type) {
+			case string:
+				if _, ok := objectGet(obj, v); ok && isMap {
+					res = append(res, v)
+				}
+			case int:
+				if isSlice {
+					appendIdx(v)
 				}
 			}
 		}
-	case reflect.Slice:
-		for i := 0; i < reflect.ValueOf(obj).Len(); i++ {
-			tmp, _ := get_idx(obj, i)
-			recursion_del(tmp, key, left, right)
+	case "filter":
+		for _, k := range select_keys(obj, root, step{"wildcard", "", nil}) {
+			//出错时视为不满足
+			if ok, err := eval_filter(child_value(obj, k), root, s.args.(*filterExpr)); err == nil && ok {
+				res = append(res, k)
+			}
 		}
 	}
-	return
+	return res
 }
 
-func filter_get_from_explicit_path(obj interface{}, path string) (interface{}, error) {
-	steps, err := tokenize(path)
-	//fmt.Println("f: steps: ", steps, err)
-	//fmt.Println(path, steps)
-	if err != nil {
-		return nil, err
+//通过键值(string)或下标(int)取得子节点
+func child_value(obj, key interface{}) interface{} {
+	if k, ok := key.(string); ok {
+		v, _ := objectGet(obj, k)
+		return v
 	}
-	if steps[0] != "@" && steps[0] != "$" {
-		return nil, fmt.Errorf("$ or @ should in front of path")
+	return arrayGet(obj, key.(int))
+}
+
+//在obj和它的所有后代节点上执行步骤s，按文档顺序返回所有选中的子节点
+//嵌套在选中节点中的节点同样会被选中
+func get_descendants(obj, root interface{}, s step) ([]interface{}, error) {
+	if reflect.TypeOf(obj) == nil {
+		return nil, ErrGetFromNullObj
 	}
-	steps = steps[1:]
-	xobj := obj
-	//fmt.Println("f: xobj", xobj)
-	for _, s := range steps {
-		op, key, args, err := parse_token(s)
-		// "key", "idx"
-		switch op {
-		case "key":
-			xobj, err = get_key(xobj, key)
-			if err != nil {
-				return nil, err
-			}
-		case "idx":
-			if len(args.([]int)) != 1 {
-				return nil, fmt.Errorf("don't support multiple index in filter")
-			}
-			xobj, err = get_key(xobj, key)
-			if err != nil {
-				return nil, err
-			}
-			xobj, err = get_idx(xobj, args.([]int)[0])
-			if err != nil {
-				return nil, err
-			}
-		default:
-			return nil, fmt.Errorf("expression don't support in filter")
+	res := []interface{}{}
+	recursion_walk(obj, nil, nil, func(v, parent, key interface{}) {
+		for _, k := range select_keys(v, root, s) {
+			res = append(res, child_value(v, k))
 		}
-	}
-	return xobj, nil
+	})
+	return res, nil
 }
 
 //通过key查找对象Map中是否有对应的值
@@ -834,198 +591,178 @@ func get_key(obj interface{}, key string) (interface{}, error) {
 		return nil, fmt.Errorf("key error: %s not found in object", key)
 	case reflect.Slice:
 		// 切片需要遍历所有的切片对象获得所有相应的值
+		return key_values(obj, key), nil
+	default:
+		return nil, fmt.Errorf("object is not map or slice")
+	}
+}
+
+//multi为true时obj是上一步得到的多个结果组成的列表，对每个结果取键值后合并成一个列表
+func get_key_multi(obj interface{}, key string, multi bool) (interface{}, error) {
+	if !multi {
+		return get_key(obj, key)
+	}
+	return key_values(obj, key), nil
+}
+
+//与key_children相同，对象上取键值，数组上对每个元素取键值后合并，不存在时忽略
+func key_values(obj interface{}, key string) []interface{} {
+	res := []interface{}{}
+	if v, ok := objectGet(obj, key); ok {
+		return append(res, v)
+	}
+	if length, ok := arrayLen(obj); ok {
+		for i := 0; i < length; i++ {
+			res = append(res, key_values(arrayGet(obj, i), key)...)
+		}
+	}
+	return res
+}
+
+//通过通配符获取对象的所有成员(按键值排序)或数组的所有元素
+//multi为true时obj是上一步得到的多个结果组成的列表，对其中每个元素分别获取后合并
+func get_wildcard(obj interface{}, multi bool) ([]interface{}, error) {
+	if reflect.TypeOf(obj) == nil {
+		return nil, ErrGetFromNullObj
+	}
+	if length, ok := arrayLen(obj); ok && multi {
 		res := []interface{}{}
-		for i := 0; i < reflect.ValueOf(obj).Len(); i++ {
-			tmp, _ := get_idx(obj, i)
-			if v, err := get_key(tmp, key); err == nil {
-				res = append(res, v)
+		for i := 0; i < length; i++ {
+			//没有成员的值直接忽略
+			if children, err := get_wildcard(arrayGet(obj, i), false); err == nil {
+				res = append(res, children...)
 			}
 		}
 		return res, nil
-	default:
-		return nil, fmt.Errorf("object is not map or slice")
 	}
+	if keys, ok := objectKeys(obj); ok {
+		res := make([]interface{}, 0, len(keys))
+		for _, k := range keys {
+			v, _ := objectGet(obj, k)
+			res = append(res, v)
+		}
+		return res, nil
+	}
+	if length, ok := arrayLen(obj); ok {
+		res := make([]interface{}, 0, length)
+		for i := 0; i < length; i++ {
+			res = append(res, arrayGet(obj, i))
+		}
+		return res, nil
+	}
+	return nil, fmt.Errorf("object is not map or slice")
 }
 
-//通过key操作json对象
-func operate_key(obj interface{}, key string, mode string, opertFunc string) error {
+//通过键值和下标的组合获取数据
+//map中只取存在的键值，数组中只取存在的下标，数组中的键值会从每个元素中获取
+func get_union(obj interface{}, args []interface{}) ([]interface{}, error) {
 	if reflect.TypeOf(obj) == nil {
-		return ErrGetFromNullObj
+		return nil, ErrGetFromNullObj
 	}
-
-	switch reflect.TypeOf(obj).Kind() {
-	case reflect.Map:
-		// if obj came from stdlib json, its highly likely to be a map[string]interface{}
-		// in which case we can save having to iterate the map keys to work out if the
-		// key exists
-		if jsonMap, ok := obj.(map[string]interface{}); ok {
-			_, exists := jsonMap[key]
-			if !exists {
-				return fmt.Errorf("key error: %s not found in object", key)
+	res := []interface{}{}
+	kind := reflect.TypeOf(obj).Kind()
+	if kind != reflect.Map && kind != reflect.Slice {
+		return nil, fmt.Errorf("object is not map or slice")
+	}
+	for _, arg := range args {
+		switch v := arg.(type) {
+		case string:
+			val, err := get_key(obj, v)
+			if err != nil {
+				continue
 			}
-			if mode == conf.DataDesensitizationControl {
-				var err error
-				if desensitFunc, ok := DesensitizationFuncs[opertFunc]; ok {
-					err = desensitFunc(jsonMap, key)
-				} else {
-					return fmt.Errorf("%s not found in function map", opertFunc)
-				}
-				if err != nil {
-					return err
-				}
-				return nil
-			} else if mode == conf.DataFieldControl {
-				delete(jsonMap, key)
-				return nil
+			if kind == reflect.Slice {
+				res = append(res, val.([]interface{})...)
 			} else {
-				return nil
+				res = append(res, val)
 			}
-		}
-	case reflect.Slice:
-		// slice we should get from all objects in it.
-		for i := 0; i < reflect.ValueOf(obj).Len(); i++ {
-			tmp, _ := get_idx(obj, i)
-
-			if mode == conf.DataDesensitizationControl {
-				if err := operate_key(tmp, key, conf.DataDesensitizationControl, opertFunc); err == nil {
-				} else {
-					return err
-				}
-			} else if mode == conf.DataFieldControl {
-				if err := operate_key(tmp, key, conf.DataFieldControl, opertFunc); err == nil {
-				} else {
-					return err
-				}
+		case int:
+			if kind != reflect.Slice {
+				continue
+			}
+			if val, err := get_idx(obj, v); err == nil {
+				res = append(res, val)
 			}
 		}
-		return nil
-	default:
-		return fmt.Errorf("object is not map")
 	}
-	return nil
+	return res, nil
 }
 
 //脱敏实际操作函数，通过传过来的脱敏规则，进行脱敏
-func handle_desensitization(jsonMap map[string]interface{}, key string, rule desensitization) error {
-	value := []rune(jsonMap[key].(string))
-	valueLen := len(value)
-	if rule.start > valueLen {
-		return fmt.Errorf("start pos error: %s is over the value length", rule.start)
+func handle_desensitization(jsonMap map[string]interface{}, key string, rule MaskRule) error {
+	value, err := mask_value(jsonMap, key)
+	if err != nil {
+		return err
 	}
-	for i := rule.start; i < rule.start+rule.size && i < valueLen; i++ {
-		value[i] = int32(rule.displayChar[0])
+	res, err := rule.Mask(value)
+	if err != nil {
+		return err
 	}
-	jsonMap[key] = string(value)
+	jsonMap[key] = res
 	return nil
 }
 
 //车牌号脱敏函数
 func carNumberDesensitization(jsonMap map[string]interface{}, key string) error {
+	value, err := mask_value(jsonMap, key)
+	if err != nil {
+		return err
+	}
 	//普通燃油车牌号过滤方案
 	fuelCar, _ := regexp.MatchString(
 		`[京津沪渝冀豫云辽黑湘皖鲁新苏浙赣鄂桂甘晋蒙陕吉闽贵粤青藏川宁琼使领A-Z]{1}[A-Z]{1}[A-HJ-NP-Z0-9]{4}[A-HJ-NP-Z0-9挂学警港澳]{1}`,
-		jsonMap[key].(string))
-	var rule desensitization
+		value)
+	var rule MaskRule
 	if fuelCar {
-		rule = desensitization{
-			displayChar: "*",
-			start:       1,
-			size:        3,
+		rule = MaskRule{
+			Start: 1,
+			Size:  3,
 		}
 	} else {
 		return fmt.Errorf("carnumber error")
 	}
-	err := handle_desensitization(jsonMap, key, rule)
+	err = handle_desensitization(jsonMap, key, rule)
 	return err
 }
 
-//手机号脱敏函数
-func phoneDesensitization(jsonMap map[string]interface{}, key string) error {
-	phoneLen := len(jsonMap[key].(string))
-	var rule desensitization
-	//普通11位电话号码过滤规范
-	if phoneLen == 11 {
-		rule = desensitization{
-			displayChar: "*",
-			start:       3,
-			size:        4,
-		}
-	} else if phoneLen == 12 {
-		rule = desensitization{
-			displayChar: "*",
-			start:       4,
-			size:        4,
-		}
-	} else if phoneLen == 7 || phoneLen == 6 {
-		rule = desensitization{
-			displayChar: "*",
-			start:       1,
-			size:        4,
-		}
-	} else if phoneLen <= 5 {
-		return nil
-	} else {
-		return fmt.Errorf("phonenumber error")
-	}
-	err := handle_desensitization(jsonMap, key, rule)
-	return err
+//手机号脱敏策略
+var phoneStrategy = &MaskStrategy{
+	Name:  PhoneDesensitization,
+	Error: "phonenumber error",
+	Rules: []MaskRule{
+		//普通11位电话号码过滤规范
+		{MinLength: 11, MaxLength: 11, Start: 3, Size: 4},
+		{MinLength: 12, MaxLength: 12, Start: 4, Size: 4},
+		{MinLength: 6, MaxLength: 7, Start: 1, Size: 4},
+		{MaxLength: 5, Skip: true},
+	},
 }
 
-//身份证脱敏函数
-func idCardNumberDesensitization(jsonMap map[string]interface{}, key string) error {
-	idCardLen := len(jsonMap[key].(string))
-	var rule desensitization
-	//不同身份证过滤方法
-	//大陆身份证
-	if idCardLen == 18 {
-		rule = desensitization{
-			displayChar: "*",
-			start:       6,
-			size:        8,
-		}
-	} else if idCardLen == 8 || idCardLen == 7 || idCardLen == 10 { //香港台湾身份证
-		rule = desensitization{
-			displayChar: "*",
-			start:       1,
-			size:        4,
-		}
-	} else { //其他情况
-		rule = desensitization{
-			displayChar: "*",
-			start:       1,
-			size:        4,
-		}
-	}
-	err := handle_desensitization(jsonMap, key, rule)
-	return err
+//身份证脱敏策略
+var idCardNumberStrategy = &MaskStrategy{
+	Name: IdCardNumberDesensitization,
+	Rules: []MaskRule{
+		//大陆身份证
+		{MinLength: 18, MaxLength: 18, Start: 6, Size: 8},
+		//香港台湾身份证及其他情况
+		{Start: 1, Size: 4},
+	},
 }
 
-//名字脱敏函数
-func nameDesensitization(jsonMap map[string]interface{}, key string) error {
-	value := []rune(jsonMap[key].(string))
-	idCardLen := len(value)
-	var rule desensitization
-	//不同身份证过滤方法
-	//大陆身份证
-	if idCardLen == 2 {
-		rule = desensitization{
-			displayChar: "*",
-			start:       1,
-			size:        1,
-		}
-	} else { //其他情况
-		rule = desensitization{
-			displayChar: "*",
-			start:       1,
-			size:        idCardLen - 2,
-		}
-	}
-	err := handle_desensitization(jsonMap, key, rule)
-	return err
+//名字脱敏策略，只保留第一个字，三个字以上时同时保留最后一个字
+var nameStrategy = &MaskStrategy{
+	Name: NameDesensitization,
+	Rules: []MaskRule{
+		{MinLength: 2, MaxLength: 2, Start: 1, Size: 1},
+		{KeepFirst: 1, KeepLast: 1},
+	},
 }
 
 //通过下标获得切片中的元素
 func get_idx(obj interface{}, idx int) (interface{}, error) {
+	if reflect.TypeOf(obj) == nil {
+		return nil, ErrGetFromNullObj
+	}
 	switch reflect.TypeOf(obj).Kind() {
 	case reflect.Slice:
 		length := reflect.ValueOf(obj).Len()
@@ -1047,190 +784,110 @@ func get_idx(obj interface{}, idx int) (interface{}, error) {
 	}
 }
 
-//为了可以支持负数范围表示方法
-//通过切片实际长度和传进来的范围，转换为合理的范围
-func transforRange(len int, frm interface{}, to interface{}) (int, int, error) {
-	_frm := 0
-	_to := len
-	if frm == nil {
-		frm = 0
-	}
-	if to == nil {
-		to = len - 1
-	}
-	if fv, ok := frm.(int); ok == true {
-		if fv < 0 {
-			_frm = len + fv
-		} else {
-			_frm = fv
+//为了可以支持负数范围和步长
+//通过切片实际长度和传进来的范围，转换为范围中所有的下标
+//与RFC 9535不同，to包含在范围内；超出切片长度的范围会被截断，不会报错
+func rangeIndices(len int, frm, to, step interface{}) []int {
+	if to != nil {
+		_to := to.(int)
+		if _to < 0 {
+			_to = len + _to
 		}
-	}
-	if tv, ok := to.(int); ok == true {
-		if tv < 0 {
-			_to = len + tv + 1
+		//转换成不包含to的范围
+		if step != nil && step.(int) < 0 {
+			_to--
 		} else {
-			_to = tv + 1
+			_to++
 		}
+		//已经越过切片的开头
+		if _to < 0 {
+			_to = -len - 1
+		}
+		to = _to
 	}
-	if _frm < 0 || _frm >= len {
-		return -1, -1, fmt.Errorf("index [from] out of range: len: %v, from: %v", len, frm)
-	}
-	if _to < 0 || _to > len {
-		return -1, -1, fmt.Errorf("index [to] out of range: len: %v, to: %v", len, to)
-	}
-	return _frm, _to, nil
+	return sliceIndices(len, frm, to, step)
 }
 
-//在切片中通过范围获得范围中的值
-func get_range(obj, frm, to interface{}) (interface{}, error) {
+//在切片中通过范围获得范围中的值，step为负数时倒序获取
+func get_range(obj, frm, to, step interface{}) (interface{}, error) {
+	if reflect.TypeOf(obj) == nil {
+		return nil, ErrGetFromNullObj
+	}
 	switch reflect.TypeOf(obj).Kind() {
 	case reflect.Slice:
-		length := reflect.ValueOf(obj).Len()
-		_frm, _to, err := transforRange(length, frm, to)
-		if err != nil {
-			return nil, err
+		objv := reflect.ValueOf(obj)
+		idx := rangeIndices(objv.Len(), frm, to, step)
+		res_v := reflect.MakeSlice(objv.Type(), 0, len(idx))
+		for _, i := range idx {
+			res_v = reflect.Append(res_v, objv.Index(i))
 		}
-		//fmt.Println("_frm, _to: ", _frm, _to)
-		res_v := reflect.ValueOf(obj).Slice(_frm, _to)
-
 		return res_v.Interface(), nil
 	default:
 		return nil, fmt.Errorf("object is not Slice")
 	}
 }
 
+//编译 /pattern/flags 形式的正则表达式，flags转换为(?flags)，例如 /.*REES/i
 func regFilterCompile(rule string) (*regexp.Regexp, error) {
 	runes := []rune(rule)
 	if len(runes) <= 2 {
 		return nil, errors.New("empty rule")
 	}
 
-	if runes[0] != '/' || runes[len(runes)-1] != '/' {
+	end := strings.LastIndexByte(rule, '/')
+	if runes[0] != '/' || end == 0 {
 		return nil, errors.New("invalid syntax. should be in `/pattern/` form")
 	}
-	runes = runes[1 : len(runes)-1]
-	return regexp.Compile(string(runes))
-}
-
-//修改过滤操作
-func operate_filter(obj interface{}, root interface{}, key string, filter string, mode string, opertFunc string) error {
-	opertObj, err := get_key(obj, key)
-	if err != nil {
-		return err
-	}
-	lp, op, rp, err := parse_filter(filter)
-	if err != nil {
-		return err
+	pattern, flags := rule[1:end], rule[end+1:]
+	if len(flags) > 0 {
+		pattern = "(?" + flags + ")" + pattern
 	}
+	return regexp.Compile(pattern)
+}
 
+//数组中返回满足过滤条件的元素，map满足过滤条件时返回只包含它自身的列表
+//multi为true时obj是上一步得到的多个结果组成的列表，对其中每个元素分别过滤后合并，不是数组或map的元素忽略
+func get_filtered(obj, root interface{}, filter *filterExpr, multi bool) ([]interface{}, error) {
 	res := []interface{}{}
-
-	switch reflect.TypeOf(opertObj).Kind() {
-	case reflect.Slice:
-		obj2 := obj.(map[string]interface{})
-		if mode == conf.DataFieldControl {
-			for i := 0; i < reflect.ValueOf(opertObj).Len(); i++ {
-				tmp := reflect.ValueOf(opertObj).Index(i).Interface()
-				ok, err := eval_filter(tmp, root, lp, op, rp)
-				if err != nil {
-					return err
-				}
-				if ok == false {
-					res = append(res, tmp)
+	if length, ok := arrayLen(obj); ok && multi {
+		for i := 0; i < length; i++ {
+			elem := arrayGet(obj, i)
+			if _, ok := objectKeys(elem); !ok {
+				if _, ok := arrayLen(elem); !ok {
+					continue
 				}
 			}
-			obj2[key] = res
-		} else if mode == conf.DataDesensitizationControl {
-			return fmt.Errorf("not DesensitizationControl on json object")
-		}
-		return nil
-	case reflect.Map:
-		opertObj2 := obj.(map[string]interface{})
-		ok, err := eval_filter(opertObj, root, lp, op, rp)
-		if err != nil {
-			return err
-		}
-		if ok == true {
-			if mode == conf.DataFieldControl {
-				delete(opertObj2, key)
-			} else if mode == conf.DataDesensitizationControl {
-				return fmt.Errorf("not DesensitizationControl on json object")
+			matched, err := get_filtered(elem, root, filter, false)
+			if err != nil {
+				return nil, err
 			}
+			res = append(res, matched...)
 		}
-	default:
-		return fmt.Errorf("don't support filter on this type: %v", reflect.TypeOf(obj).Kind())
+		return res, nil
 	}
-
-	return nil
-}
-
-func get_filtered(obj, root interface{}, filter string) ([]interface{}, error) {
-	lp, op, rp, err := parse_filter(filter)
-	if err != nil {
-		return nil, err
+	if reflect.TypeOf(obj) == nil {
+		return nil, ErrGetFromNullObj
 	}
 
-	res := []interface{}{}
-
 	switch reflect.TypeOf(obj).Kind() {
 	case reflect.Slice:
-		if op == "=~" {
-			// regexp
-			pat, err := regFilterCompile(rp)
+		for i := 0; i < reflect.ValueOf(obj).Len(); i++ {
+			tmp := reflect.ValueOf(obj).Index(i).Interface()
+			ok, err := eval_filter(tmp, root, filter)
 			if err != nil {
 				return nil, err
 			}
-
-			for i := 0; i < reflect.ValueOf(obj).Len(); i++ {
-				tmp := reflect.ValueOf(obj).Index(i).Interface()
-				ok, err := eval_reg_filter(tmp, root, lp, pat)
-				if err != nil {
-					return nil, err
-				}
-				if ok == true {
-					res = append(res, tmp)
-				}
-			}
-		} else {
-			for i := 0; i < reflect.ValueOf(obj).Len(); i++ {
-				tmp := reflect.ValueOf(obj).Index(i).Interface()
-				ok, err := eval_filter(tmp, root, lp, op, rp)
-				if err != nil {
-					return nil, err
-				}
-				if ok == true {
-					res = append(res, tmp)
-				}
+			if ok == true {
+				res = append(res, tmp)
 			}
 		}
-		return res, nil
 	case reflect.Map:
-		if op == "=~" {
-			// regexp
-			pat, err := regFilterCompile(rp)
-			if err != nil {
-				return nil, err
-			}
-
-			for _, kv := range reflect.ValueOf(obj).MapKeys() {
-				tmp := reflect.ValueOf(obj).MapIndex(kv).Interface()
-				ok, err := eval_reg_filter(tmp, root, lp, pat)
-				if err != nil {
-					return nil, err
-				}
-				if ok == true {
-					res = append(res, tmp)
-				}
-			}
-		} else {
-			ok, err := eval_filter(obj, root, lp, op, rp)
-			if err != nil {
-				return nil, err
-			}
-			if ok == true {
-				res = append(res, obj)
-			}
-			return res, nil
+		ok, err := eval_filter(obj, root, filter)
+		if err != nil {
+			return nil, err
+		}
+		if ok == true {
+			res = append(res, obj)
 		}
 	default:
 		return nil, fmt.Errorf("don't support filter on this type: %v", reflect.TypeOf(obj).Kind())
@@ -1239,199 +896,119 @@ func get_filtered(obj, root interface{}, filter string) ([]interface{}, error) {
 	return res, nil
 }
 
-// @.isbn                 => @.isbn, exists, nil
-// @.price < 10           => @.price, <, 10
-// @.price <= $.expensive => @.price, <=, $.expensive
-// @.author =~ /.*REES/i  => @.author, match, /.*REES/i
-
-func parse_filter(filter string) (lp string, op string, rp string, err error) {
-	tmp := ""
-
-	stage := 0
-	str_embrace := false
-	for idx, c := range filter {
-		switch c {
-		case '\'':
-			if str_embrace == false {
-				str_embrace = true
-			} else {
-				switch stage {
-				case 0:
-					lp = tmp
-				case 1:
-					op = tmp
-				case 2:
-					rp = tmp
-				}
-				tmp = ""
-			}
-		case ' ':
-			if str_embrace == true {
-				tmp += string(c)
-				continue
-			}
-			switch stage {
-			case 0:
-				lp = tmp
-			case 1:
-				op = tmp
-			case 2:
-				rp = tmp
-			}
-			tmp = ""
-
-			stage += 1
-			if stage > 2 {
-				return "", "", "", errors.New(fmt.Sprintf("invalid char at %d: `%c`", idx, c))
-			}
-		default:
-			tmp += string(c)
+// @.isbn                          => @.isbn存在且不为null
+// @.price < 10 && @.isbn          => 先比较再做逻辑运算，&& 优先于 ||
+// !(@.price <= $.expensive)       => 括号内整体取反
+// @.author =~ /.*REES/i           => 正则匹配，只对字符串生效
+// @.category in {fiction, 'poem'} => 是否等于其中一个值，noin 相反
+
+//计算过滤表达式的值
+//obj 当前元素(@)的值，root 根节点($)的值
+//&& 和 || 短路求值，比较时有一边不存在则只有 != 成立(两边都不存在时 == 成立)
+func eval_filter(obj, root interface{}, filter *filterExpr) (res bool, err error) {
+	switch filter.kind {
+	case "or":
+		if res, err = eval_filter(obj, root, filter.args[0]); err != nil || res {
+			return res, err
 		}
-	}
-	if tmp != "" {
-		switch stage {
-		case 0:
-			lp = tmp
-			op = "exists"
-		case 1:
-			op = tmp
-		case 2:
-			rp = tmp
+		return eval_filter(obj, root, filter.args[1])
+	case "and":
+		if res, err = eval_filter(obj, root, filter.args[0]); err != nil || !res {
+			return res, err
 		}
-		tmp = ""
-	}
-	return lp, op, rp, err
-}
-
-func parse_filter_v1(filter string) (lp string, op string, rp string, err error) {
-	tmp := ""
-	istoken := false
-	for _, c := range filter {
-		if istoken == false && c != ' ' {
-			istoken = true
+		return eval_filter(obj, root, filter.args[1])
+	case "not":
+		res, err = eval_filter(obj, root, filter.args[0])
+		return !res, err
+	case "query":
+		return filter_exists(obj, root, filter), nil
+	case "compare":
+		lp_v, lok := eval_filter_value(obj, root, filter.args[0])
+		rp_v, rok := eval_filter_value(obj, root, filter.args[1])
+		switch filter.op {
+		case "=~":
+			s, ok := lp_v.(string)
+			return lok && ok && rp_v.(*regexp.Regexp).MatchString(s), nil
+		case "in", "noin":
+			if !lok {
+				return filter.op == "noin", nil
+			}
+			values := rp_v.([]interface{})
+			if filter.coerce {
+				lp_v = coerce_number(lp_v)
+				values = make([]interface{}, len(values))
+				for i, v := range rp_v.([]interface{}) {
+					values[i] = coerce_number(v)
+				}
+			}
+			return contain_any(lp_v, values, filter.op)
 		}
-		if istoken == true && c == ' ' {
-			istoken = false
+		if !lok || !rok {
+			switch filter.op {
+			case "==":
+				return lok == rok, nil
+			case "!=":
+				return lok != rok, nil
+			}
+			return false, nil
 		}
-		if istoken == true {
-			tmp += string(c)
+		if filter.coerce {
+			lp_v, rp_v = coerce_number(lp_v), coerce_number(rp_v)
 		}
-		if istoken == false && tmp != "" {
-			if lp == "" {
-				lp = tmp[:]
-				tmp = ""
-			} else if op == "" {
-				op = tmp[:]
-				tmp = ""
-			} else if rp == "" {
-				rp = tmp[:]
-				tmp = ""
-			}
-		}
-	}
-	if tmp != "" && lp == "" && op == "" && rp == "" {
-		lp = tmp[:]
-		op = "exists"
-		rp = ""
-		err = nil
-		return
-	} else if tmp != "" && rp == "" {
-		rp = tmp[:]
-		tmp = ""
+		return cmp_any(lp_v, rp_v, filter.op)
 	}
-	return lp, op, rp, err
+	return false, fmt.Errorf("expression don't support in filter")
 }
 
-func eval_reg_filter(obj, root interface{}, lp string, pat *regexp.Regexp) (res bool, err error) {
-	if pat == nil {
-		return false, errors.New("nil pat")
-	}
-	lp_v, err := get_lp_v(obj, root, lp)
-	if err != nil {
-		return false, err
-	}
-	switch v := lp_v.(type) {
-	case string:
-		return pat.MatchString(v), nil
-	default:
-		return false, errors.New("only string can match with regular expression")
-	}
-}
-
-func get_lp_v(obj, root interface{}, lp string) (interface{}, error) {
-	var lp_v interface{}
-	if strings.HasPrefix(lp, "@.") {
-		return filter_get_from_explicit_path(obj, lp)
-	} else if strings.HasPrefix(lp, "$.") {
-		return filter_get_from_explicit_path(root, lp)
-	} else {
-		lp_v = lp
+//过滤表达式中literal或query的值，query没有取到值时ok为false
+func eval_filter_value(obj, root interface{}, filter *filterExpr) (v interface{}, ok bool) {
+	switch filter.kind {
+	case "literal":
+		return filter.value, true
+	case "query":
+		start := obj
+		if filter.query.root == "$" {
+			start = root
+		}
+		v, err := (&Compiled{steps: filter.steps}).Lookup(start)
+		return v, err == nil
 	}
-	return lp_v, nil
+	return nil, false
 }
 
-func eval_filter(obj, root interface{}, lp, op, rp string) (res bool, err error) {
-	lp_v, err := get_lp_v(obj, root, lp)
-
-	if op == "exists" {
-		return lp_v != nil, nil
-	} else if op == "=~" {
-		return false, fmt.Errorf("not implemented yet")
-	} else {
-		//匹配in,not in操作符
-		var InOperatRegexp, _ = regexp.Compile("{.*}")
-		if InOperatRegexp.Match([]byte(rp)) {
-			var rp_vs []string
-			rp = rp[1 : len(rp)-1]
-			rp_vs = strings.Split(rp, ",")
-			for k, v := range rp_vs {
-				var tempv interface{}
-				if strings.HasPrefix(rp, "@.") {
-					tempv, err = filter_get_from_explicit_path(obj, v)
-				} else if strings.HasPrefix(rp, "$.") {
-					tempv, err = filter_get_from_explicit_path(root, v)
-				} else {
-					tempv = v
-				}
-				rp_vs[k] = tempv.(string)
-			}
-			return contain_any(lp_v, rp_vs, op)
-		} else {
-			//匹配 ==,>,<,>=,<=
-			var rp_v interface{}
-			if strings.HasPrefix(rp, "@.") {
-				rp_v, err = filter_get_from_explicit_path(obj, rp)
-			} else if strings.HasPrefix(rp, "$.") {
-				rp_v, err = filter_get_from_explicit_path(root, rp)
-			} else {
-				rp_v = rp
-			}
-			//fmt.Printf("lp_v: %v, rp_v: %v\n", lp_v, rp_v)
-			return cmp_any(lp_v, rp_v, op)
+//过滤表达式中的query是否取到了值
+//取单个值时值为null视为不存在；取多个值时(例如数组元素上的@.name)结果列表为空视为不存在
+func filter_exists(obj, root interface{}, filter *filterExpr) bool {
+	start := obj
+	if filter.query.root == "$" {
+		start = root
+	}
+	nodes := []*node{{value: start}}
+	multi := false
+	for _, s := range filter.steps {
+		var err error
+		nodes, multi, err = step_nodes(nodes, multi, root, s, false, false)
+		if err != nil {
+			return false
 		}
 	}
+	if multi {
+		return len(nodes) > 0
+	}
+	return len(nodes) == 1 && nodes[0].value != nil
 }
 
-func isNumber(o interface{}) bool {
-	switch v := o.(type) {
-	case int, int8, int16, int32, int64:
-		return true
-	case uint, uint8, uint16, uint32, uint64:
-		return true
-	case float32, float64:
-		return true
-	case string:
-		_, err := strconv.ParseFloat(v, 64)
-		if err == nil {
-			return true
-		} else {
-			return false
+//把数字形式的字符串转换成数字，其它值原样返回
+func coerce_number(obj interface{}) interface{} {
+	if v, ok := obj.(string); ok && len(v) > 0 && scanNumber(v, 0) == len(v) {
+		if f, err := strconv.ParseFloat(v, 64); err == nil {
+			return f
 		}
 	}
-	return false
+	return obj
 }
 
-func contain_any(obj1 interface{}, obj2 []string, op string) (bool, error) {
+func contain_any(obj1 interface{}, obj2 []interface{}, op string) (bool, error) {
 	switch op {
 	case "in":
 		for _, v := range obj2 {
@@ -1454,31 +1031,14 @@ func contain_any(obj1 interface{}, obj2 []string, op string) (bool, error) {
 	}
 }
 
+//按json的类型比较两个值，不做类型转换
+//数字之间按数值比较，字符串之间按字节序比较，数组和对象逐个元素判断是否相等
+//不同类型的值只满足 !=，<、> 等只对两个数字或两个字符串成立
 func cmp_any(obj1, obj2 interface{}, op string) (bool, error) {
 	switch op {
-	case "<", "<=", "==", ">=", ">":
+	case "<", "<=", "==", "!=", ">=", ">":
 	default:
-		return false, fmt.Errorf("op should only be <, <=, ==, >= and >")
+		return false, fmt.Errorf("op should only be <, <=, ==, !=, >= and >")
 	}
-
-	var exp string
-	if isNumber(obj1) && isNumber(obj2) {
-		exp = fmt.Sprintf(`%v %s %v`, obj1, op, obj2)
-	} else {
-		exp = fmt.Sprintf(`"%v" %s "%v"`, obj1, op, obj2)
-	}
-	//fmt.Println("exp: ", exp)
-	fset := token.NewFileSet()
-	res, err := types.Eval(fset, nil, 0, exp)
-	if err != nil {
-		return false, err
-	}
-	if res.IsValue() == false || (res.Value.String() != "false" && res.Value.String() != "true") {
-		return false, fmt.Errorf("result should only be true or false")
-	}
-	if res.Value.String() == "true" {
-		return true, nil
-	}
-
-	return false, nil
+	return compareValues(obj1, obj2, op), nil
 }
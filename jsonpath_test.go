@@ -3,10 +3,9 @@ package jsonpath
 import (
 	"encoding/json"
 	"fmt"
-	"go/token"
-	"go/types"
 	"reflect"
 	"regexp"
+	"sync"
 	"testing"
 )
 
@@ -57,32 +56,32 @@ func init() {
 
 func Test_jsonpath_JsonPathLookup_1(t *testing.T) {
 	// key from root
-	res, _ := JsonPathLookup(json_data, "$.expensive")
+	res, _ := JsonPathLookUp(json_data, "$.expensive")
 	if res_v, ok := res.(float64); ok != true || res_v != 10.0 {
 		t.Errorf("expensive should be 10")
 	}
 
 	// single index
-	res, _ = JsonPathLookup(json_data, "$.store.book[0].price")
+	res, _ = JsonPathLookUp(json_data, "$.store.book[0].price")
 	if res_v, ok := res.(float64); ok != true || res_v != 8.95 {
 		t.Errorf("$.store.book[0].price should be 8.95")
 	}
 
 	// nagtive single index
-	res, _ = JsonPathLookup(json_data, "$.store.book[-1].isbn")
+	res, _ = JsonPathLookUp(json_data, "$.store.book[-1].isbn")
 	if res_v, ok := res.(string); ok != true || res_v != "0-395-19395-8" {
 		t.Errorf("$.store.book[-1].isbn should be \"0-395-19395-8\"")
 	}
 
 	// multiple index
-	res, err := JsonPathLookup(json_data, "$.store.book[0,1].price")
+	res, err := JsonPathLookUp(json_data, "$.store.book[0,1].price")
 	t.Log(err, res)
 	if res_v, ok := res.([]interface{}); ok != true || res_v[0].(float64) != 8.95 || res_v[1].(float64) != 12.99 {
 		t.Errorf("exp: [8.95, 12.99], got: %v", res)
 	}
 
 	// multiple index
-	res, err = JsonPathLookup(json_data, "$.store.book[0,1].title")
+	res, err = JsonPathLookUp(json_data, "$.store.book[0,1].title")
 	t.Log(err, res)
 	if res_v, ok := res.([]interface{}); ok != true {
 		if res_v[0].(string) != "Sayings of the Century" || res_v[1].(string) != "Sword of Honour" {
@@ -91,21 +90,21 @@ func Test_jsonpath_JsonPathLookup_1(t *testing.T) {
 	}
 
 	// full array
-	res, err = JsonPathLookup(json_data, "$.store.book[0:].price")
+	res, err = JsonPathLookUp(json_data, "$.store.book[0:].price")
 	t.Log(err, res)
 	if res_v, ok := res.([]interface{}); ok != true || res_v[0].(float64) != 8.95 || res_v[1].(float64) != 12.99 || res_v[2].(float64) != 8.99 || res_v[3].(float64) != 22.99 {
 		t.Errorf("exp: [8.95, 12.99, 8.99, 22.99], got: %v", res)
 	}
 	
 	// range
-	res, err = JsonPathLookup(json_data, "$.store.book[0:1].price")
+	res, err = JsonPathLookUp(json_data, "$.store.book[0:1].price")
 	t.Log(err, res)
 	if res_v, ok := res.([]interface{}); ok != true || res_v[0].(float64) != 8.95 || res_v[1].(float64) != 12.99 {
 		t.Errorf("exp: [8.95, 12.99], got: %v", res)
 	}
 
 	// range
-	res, err = JsonPathLookup(json_data, "$.store.book[0:1].title")
+	res, err = JsonPathLookUp(json_data, "$.store.book[0:1].title")
 	t.Log(err, res)
 	if res_v, ok := res.([]interface{}); ok != true {
 		if res_v[0].(string) != "Sayings of the Century" || res_v[1].(string) != "Sword of Honour" {
@@ -115,7 +114,7 @@ func Test_jsonpath_JsonPathLookup_1(t *testing.T) {
 }
 
 func Test_jsonpath_JsonPathLookup_filter(t *testing.T) {
-	res, err := JsonPathLookup(json_data, "$.store.book[?(@.isbn)].isbn")
+	res, err := JsonPathLookUp(json_data, "$.store.book[?(@.isbn)].isbn")
 	t.Log(err, res)
 
 	if res_v, ok := res.([]interface{}); ok != true {
@@ -124,7 +123,7 @@ func Test_jsonpath_JsonPathLookup_filter(t *testing.T) {
 		}
 	}
 
-	res, err = JsonPathLookup(json_data, "$.store.book[?(@.price > 10)].title")
+	res, err = JsonPathLookUp(json_data, "$.store.book[?(@.price > 10)].title")
 	t.Log(err, res)
 	if res_v, ok := res.([]interface{}); ok != true {
 		if res_v[0].(string) != "Sword of Honour" || res_v[1].(string) != "The Lord of the Rings" {
@@ -132,12 +131,12 @@ func Test_jsonpath_JsonPathLookup_filter(t *testing.T) {
 		}
 	}
 
-	res, err = JsonPathLookup(json_data, "$.store.book[?(@.price > 10)]")
+	res, err = JsonPathLookUp(json_data, "$.store.book[?(@.price > 10)]")
 	t.Log(err, res)
 
-	res, err = JsonPathLookup(json_data, "$.store.book[?(@.price > $.expensive)].price")
+	res, err = JsonPathLookUp(json_data, "$.store.book[?(@.price > $.expensive)].price")
 	t.Log(err, res)
-	res, err = JsonPathLookup(json_data, "$.store.book[?(@.price < $.expensive)].price")
+	res, err = JsonPathLookUp(json_data, "$.store.book[?(@.price < $.expensive)].price")
 	t.Log(err, res)
 }
 
@@ -149,257 +148,314 @@ func Test_jsonpath_authors_of_all_books(t *testing.T) {
 		"Herman Melville",
 		"J. R. R. Tolkien",
 	}
-	res, _ := JsonPathLookup(json_data, query)
+	res, _ := JsonPathLookUp(json_data, query)
 	t.Log(res, expected)
 }
 
 var token_cases = []map[string]interface{}{
 	map[string]interface{}{
 		"query":  "$..author",
-		"tokens": []string{"$", "*", "author"},
+		"tokens": []string{"$", "..", "author"},
 	},
 	map[string]interface{}{
 		"query":  "$.store.*",
-		"tokens": []string{"$", "store", "*"},
+		"tokens": []string{"$", ".", "store", ".", "*"},
 	},
 	map[string]interface{}{
 		"query":  "$.store..price",
-		"tokens": []string{"$", "store", "*", "price"},
+		"tokens": []string{"$", ".", "store", "..", "price"},
 	},
 	map[string]interface{}{
 		"query":  "$.store.book[*].author",
-		"tokens": []string{"$", "store", "book[*]", "author"},
+		"tokens": []string{"$", ".", "store", ".", "book", "[", "*", "]", ".", "author"},
 	},
 	map[string]interface{}{
 		"query":  "$..book[2]",
-		"tokens": []string{"$", "*", "book[2]"},
-	},
-	map[string]interface{}{
-		"query":  "$..book[(@.length-1)]",
-		"tokens": []string{"$", "*", "book[(@.length-1)]"},
+		"tokens": []string{"$", "..", "book", "[", "2", "]"},
 	},
 	map[string]interface{}{
 		"query":  "$..book[0,1]",
-		"tokens": []string{"$", "*", "book[0,1]"},
+		"tokens": []string{"$", "..", "book", "[", "0", ",", "1", "]"},
 	},
 	map[string]interface{}{
 		"query":  "$..book[:2]",
-		"tokens": []string{"$", "*", "book[:2]"},
+		"tokens": []string{"$", "..", "book", "[", ":", "2", "]"},
 	},
 	map[string]interface{}{
 		"query":  "$..book[?(@.isbn)]",
-		"tokens": []string{"$", "*", "book[?(@.isbn)]"},
+		"tokens": []string{"$", "..", "book", "[", "?", "(", "@", ".", "isbn", ")", "]"},
 	},
 	map[string]interface{}{
 		"query":  "$.store.book[?(@.price < 10)]",
-		"tokens": []string{"$", "store", "book[?(@.price < 10)]"},
-	},
-	map[string]interface{}{
-		"query":  "$..book[?(@.price <= $.expensive)]",
-		"tokens": []string{"$", "*", "book[?(@.price <= $.expensive)]"},
+		"tokens": []string{"$", ".", "store", ".", "book", "[", "?", "(", "@", ".", "price", "<", "10", ")", "]"},
 	},
 	map[string]interface{}{
-		"query":  "$..book[?(@.author =~ /.*REES/i)]",
-		"tokens": []string{"$", "*", "book[?(@.author =~ /.*REES/i)]"},
+		"query":  "$..book[?(@.author =~ /.*REES\\]/i)]",
+		"tokens": []string{"$", "..", "book", "[", "?", "(", "@", ".", "author", "=~", "/.*REES\\]/i", ")", "]"},
 	},
 	map[string]interface{}{
-		"query":  "$..book[?(@.author =~ /.*REES\\]/i)]",
-		"tokens": []string{"$", "*", "book[?(@.author =~ /.*REES\\]/i)]"},
+		"query":  "$['a.b']",
+		"tokens": []string{"$", "[", "a.b", "]"},
 	},
 	map[string]interface{}{
-		"query":  "$..*",
-		"tokens": []string{"$", "*"},
+		"query":  "$.a[?(@.x == 'a]b')]",
+		"tokens": []string{"$", ".", "a", "[", "?", "(", "@", ".", "x", "==", "a]b", ")", "]"},
 	},
 	map[string]interface{}{
 		"query":  "$....author",
-		"tokens": []string{"$", "*", "author"},
+		"tokens": []string{"$", "..", "..", "author"},
 	},
 }
 
-func Test_jsonpath_tokenize(t *testing.T) {
+func Test_jsonpath_lex(t *testing.T) {
 	for idx, tcase := range token_cases {
 		t.Logf("idx[%d], tcase: %v", idx, tcase)
 		query := tcase["query"].(string)
 		expected_tokens := tcase["tokens"].([]string)
-		tokens, err := tokenize(query)
+		tokens, err := lex(query, false)
 		t.Log(err, tokens, expected_tokens)
+		if err != nil {
+			t.Errorf("failed to lex %s: %v", query, err)
+			continue
+		}
+		// 最后一个是tokEOF
+		tokens = tokens[:len(tokens)-1]
 		if len(tokens) != len(expected_tokens) {
 			t.Errorf("different length: (got)%v, (expected)%v", len(tokens), len(expected_tokens))
 			continue
 		}
 		for i := 0; i < len(expected_tokens); i++ {
-			if tokens[i] != expected_tokens[i] {
-				t.Errorf("not expected: [%d], (got)%v != (expected)%v", i, tokens[i], expected_tokens[i])
+			if tokens[i].val != expected_tokens[i] {
+				t.Errorf("not expected: [%d], (got)%v != (expected)%v", i, tokens[i].val, expected_tokens[i])
 			}
 		}
 	}
 }
 
-var parse_token_cases = []map[string]interface{}{
+var compile_cases = []map[string]interface{}{
 
 	map[string]interface{}{
-		"token": "$",
-		"op":    "root",
-		"key":   "$",
-		"args":  nil,
+		"path": "$.store",
+		"op":   "key",
+		"key":  "store",
+		"args": nil,
 	},
 	map[string]interface{}{
-		"token": "store",
-		"op":    "key",
-		"key":   "store",
-		"args":  nil,
+		"path": "$['a.b']",
+		"op":   "key",
+		"key":  "a.b",
+		"args": nil,
 	},
 
 	// idx --------------------------------------
 	map[string]interface{}{
-		"token": "book[2]",
-		"op":    "idx",
-		"key":   "book",
-		"args":  []int{2},
+		"path": "$.book[2]",
+		"op":   "idx",
+		"key":  "book",
+		"args": []int{2},
 	},
 	map[string]interface{}{
-		"token": "book[-1]",
-		"op":    "idx",
-		"key":   "book",
-		"args":  []int{-1},
+		"path": "$.book[-1]",
+		"op":   "idx",
+		"key":  "book",
+		"args": []int{-1},
 	},
 	map[string]interface{}{
-		"token": "book[0,1]",
-		"op":    "idx",
-		"key":   "book",
-		"args":  []int{0, 1},
+		"path": "$.book[0,1]",
+		"op":   "idx",
+		"key":  "book",
+		"args": []int{0, 1},
 	},
 	map[string]interface{}{
-		"token": "[0]",
-		"op":    "idx",
-		"key":   "",
-		"args":  []int{0},
+		"path": "$[0]",
+		"op":   "idx",
+		"key":  "",
+		"args": []int{0},
 	},
 
 	// range ------------------------------------
 	map[string]interface{}{
-		"token": "book[1:-1]",
-		"op":    "range",
-		"key":   "book",
-		"args":  [2]interface{}{1, -1},
+		"path": "$.book[1:-1]",
+		"op":   "range",
+		"key":  "book",
+		"args": [3]interface{}{1, -1, nil},
+	},
+	map[string]interface{}{
+		"path": "$.book[*]",
+		"op":   "wildcard",
+		"key":  "book",
+		"args": nil,
 	},
 	map[string]interface{}{
-		"token": "book[*]",
-		"op":    "range",
-		"key":   "book",
-		"args":  [2]interface{}{nil, nil},
+		"path": "$.book[:2]",
+		"op":   "range",
+		"key":  "book",
+		"args": [3]interface{}{nil, 2, nil},
 	},
 	map[string]interface{}{
-		"token": "book[:2]",
-		"op":    "range",
-		"key":   "book",
-		"args":  [2]interface{}{nil, 2},
+		"path": "$.book[-2:]",
+		"op":   "range",
+		"key":  "book",
+		"args": [3]interface{}{-2, nil, nil},
 	},
 	map[string]interface{}{
-		"token": "book[-2:]",
-		"op":    "range",
-		"key":   "book",
-		"args":  [2]interface{}{-2, nil},
+		"path": "$.book[::-1]",
+		"op":   "range",
+		"key":  "book",
+		"args": [3]interface{}{nil, nil, -1},
 	},
 
 	// filter --------------------------------
 	map[string]interface{}{
-		"token": "book[?( @.isbn      )]",
-		"op":    "filter",
-		"key":   "book",
-		"args":  "@.isbn",
+		"path": "$.book[?( @.isbn      )]",
+		"op":   "filter",
+		"key":  "book",
+		"args": "@.isbn",
 	},
 	map[string]interface{}{
-		"token": "book[?(@.price < 10)]",
-		"op":    "filter",
-		"key":   "book",
-		"args":  "@.price < 10",
+		"path": "$.book[?(@.price < 10)]",
+		"op":   "filter",
+		"key":  "book",
+		"args": "@.price < 10",
 	},
 	map[string]interface{}{
-		"token": "book[?(@.price <= $.expensive)]",
-		"op":    "filter",
-		"key":   "book",
-		"args":  "@.price <= $.expensive",
+		"path": "$.book[?(@.price <= $.expensive)]",
+		"op":   "filter",
+		"key":  "book",
+		"args": "@.price <= $.expensive",
 	},
 	map[string]interface{}{
-		"token": "book[?(@.author =~ /.*REES/i)]",
-		"op":    "filter",
-		"key":   "book",
-		"args":  "@.author =~ /.*REES/i",
+		"path": "$.book[?(@.author =~ /.*REES/i)]",
+		"op":   "filter",
+		"key":  "book",
+		"args": "@.author =~ /(?i).*REES/",
 	},
 	map[string]interface{}{
-		"token": "*",
-		"op":    "scan",
-		"key":   "*",
-		"args":  nil,
+		"path": "$.a[?(@.x == 'a]b')]",
+		"op":   "filter",
+		"key":  "a",
+		"args": "@.x == 'a]b'",
+	},
+	map[string]interface{}{
+		"path": "$.a[?((@.b[0] == 1))]",
+		"op":   "filter",
+		"key":  "a",
+		"args": "@.b[0] == 1",
+	},
+	map[string]interface{}{
+		"path": "$.book[?(@.price < 10 && (@.isbn || !@.author))]",
+		"op":   "filter",
+		"key":  "book",
+		"args": "@.price < 10 && (@.isbn || !@.author)",
+	},
+
+	// scan --------------------------------
+	map[string]interface{}{
+		"path": "$..author",
+		"op":   "scan",
+		"key":  "author",
+		"args": nil,
+	},
+	map[string]interface{}{
+		"path": "$....author",
+		"op":   "scan",
+		"key":  "author",
+		"args": nil,
+	},
+	map[string]interface{}{
+		"path": "$..book[2]",
+		"op":   "scan",
+		"key":  "book",
+		"args": []int{2},
+	},
+	map[string]interface{}{
+		"path": "$..book[1::2]",
+		"op":   "scan",
+		"key":  "book",
+		"args": [3]interface{}{1, nil, 2},
+	},
+	map[string]interface{}{
+		"path": "$..*",
+		"op":   "scan",
+		"key":  "",
+		"args": step{"wildcard", "", nil},
+	},
+	map[string]interface{}{
+		"path": "$..['a','b']",
+		"op":   "scan",
+		"key":  "",
+		"args": step{"union", "", []interface{}{"a", "b"}},
 	},
 }
 
-func Test_jsonpath_parse_token(t *testing.T) {
-	for idx, tcase := range parse_token_cases {
+func Test_jsonpath_compile_steps(t *testing.T) {
+	for idx, tcase := range compile_cases {
 		t.Logf("[%d] - tcase: %v", idx, tcase)
-		token := tcase["token"].(string)
+		path := tcase["path"].(string)
 		exp_op := tcase["op"].(string)
 		exp_key := tcase["key"].(string)
 		exp_args := tcase["args"]
 
-		op, key, args, err := parse_token(token)
+		c, err := Compile(path)
+		if err != nil {
+			t.Errorf("ERROR: failed to compile %s: %v", path, err)
+			continue
+		}
+		s := c.steps[len(c.steps)-1]
+		//过滤表达式按文本形式比较
+		if expr, ok := s.args.(*filterExpr); ok {
+			s.args = expr.String()
+		}
 		t.Logf("[%d] - expected: op: %v, key: %v, args: %v\n", idx, exp_op, exp_key, exp_args)
-		t.Logf("[%d] - got: err: %v, op: %v, key: %v, args: %v\n", idx, err, op, key, args)
-		if op != exp_op {
-			t.Errorf("ERROR: op(%v) != exp_op(%v)", op, exp_op)
-			return
+		t.Logf("[%d] - got: op: %v, key: %v, args: %v\n", idx, s.op, s.key, s.args)
+		if s.op != exp_op {
+			t.Errorf("ERROR: op(%v) != exp_op(%v)", s.op, exp_op)
+			continue
 		}
-		if key != exp_key {
-			t.Errorf("ERROR: key(%v) != exp_key(%v)", key, exp_key)
-			return
+		if s.key != exp_key {
+			t.Errorf("ERROR: key(%v) != exp_key(%v)", s.key, exp_key)
+			continue
 		}
-
-		if op == "idx" {
-			if args_v, ok := args.([]int); ok == true {
-				for i, v := range args_v {
-					if v != exp_args.([]int)[i] {
-						t.Errorf("ERROR: different args: [%d], (got)%v != (exp)%v", i, v, exp_args.([]int)[i])
-						return
-					}
-				}
-			} else {
-				t.Errorf("ERROR: idx op should expect args:[]int{} in return, (got)%v", reflect.TypeOf(args))
-				return
-			}
+		if !reflect.DeepEqual(s.args, exp_args) {
+			t.Errorf("ERROR: different args: (got)%v != (exp)%v", s.args, exp_args)
 		}
+	}
+}
 
-		if op == "range" {
-			if args_v, ok := args.([2]interface{}); ok == true {
-				fmt.Println(args_v)
-				exp_from := exp_args.([2]interface{})[0]
-				exp_to := exp_args.([2]interface{})[1]
-				if args_v[0] != exp_from {
-					t.Errorf("(from)%v != (exp_from)%v", args_v[0], exp_from)
-					return
-				}
-				if args_v[1] != exp_to {
-					t.Errorf("(to)%v != (exp_to)%v", args_v[1], exp_to)
-					return
-				}
-			} else {
-				t.Errorf("ERROR: range op should expect args:[2]interface{}, (got)%v", reflect.TypeOf(args))
-				return
-			}
+var compile_error_cases = []string{
+	"",
+	"store.book",
+	"$.",
+	"$.a[",
+	"$.a[1:2:3:4]",
+	"$..book[(@.length-1)]",
+	"$.a[?(@.b == 1]",
+	"$['a]",
+	"$.a]",
+}
+
+func Test_jsonpath_compile_error(t *testing.T) {
+	for _, path := range compile_error_cases {
+		_, err := Compile(path)
+		t.Log(path, err)
+		if err == nil {
+			t.Errorf("compile %q should fail", path)
 		}
+	}
+}
 
-		if op == "filter" {
-			if args_v, ok := args.(string); ok == true {
-				fmt.Println(args_v)
-				if exp_args.(string) != args_v {
-					t.Errorf("len(args) not expected: (got)%v != (exp)%v", len(args_v), len(exp_args.([]string)))
-					return
-				}
+func Test_jsonpath_keys_with_dots_and_brackets(t *testing.T) {
+	var j interface{}
+	json.Unmarshal([]byte(`{"a.b": {"c]d": 1}, "list": [{"x": "a]b", "v": 1}, {"x": "c", "v": 2}]}`), &j)
 
-			} else {
-				t.Errorf("ERROR: filter op should expect args:[]string{}, (got)%v", reflect.TypeOf(args))
-			}
-		}
+	res, err := JsonPathLookUp(j, `$['a.b']["c]d"]`)
+	if err != nil || res != 1.0 {
+		t.Errorf("$['a.b'][\"c]d\"] should be 1, got: %v, %v", res, err)
+	}
+
+	res, err = JsonPathLookUp(j, "$.list[?(@.x == 'a]b')].v")
+	if err != nil || fmt.Sprintf("%v", res) != "[1]" {
+		t.Errorf("filter with ']' in string should be [1], got: %v, %v", res, err)
 	}
 }
 
@@ -525,7 +581,7 @@ func Test_jsonpath_get_idx(t *testing.T) {
 func Test_jsonpath_get_range(t *testing.T) {
 	obj := []int{1, 2, 3, 4, 5}
 
-	res, err := get_range(obj, 0, 2)
+	res, err := get_range(obj, 0, 2, nil)
 	fmt.Println(err, res)
 	if err != nil {
 		t.Errorf("failed to get_range: %v", err)
@@ -535,7 +591,7 @@ func Test_jsonpath_get_range(t *testing.T) {
 	}
 
 	obj1 := []interface{}{1, 2, 3, 4, 5}
-	res, err = get_range(obj1, 3, -1)
+	res, err = get_range(obj1, 3, -1, nil)
 	fmt.Println(err, res)
 	if err != nil {
 		t.Errorf("failed to get_range: %v", err)
@@ -545,169 +601,116 @@ func Test_jsonpath_get_range(t *testing.T) {
 		t.Errorf("failed get_range: %v, expect: [4,5]", res)
 	}
 
-	res, err = get_range(obj1, nil, 2)
+	res, err = get_range(obj1, nil, 2, nil)
 	t.Logf("err: %v, res:%v", err, res)
 	if res.([]interface{})[0] != 1 || res.([]interface{})[1] != 2 {
 		t.Errorf("from support nil failed: %v", res)
 	}
 
-	res, err = get_range(obj1, nil, nil)
+	res, err = get_range(obj1, nil, nil, nil)
 	t.Logf("err: %v, res:%v", err, res)
 	if len(res.([]interface{})) != 5 {
 		t.Errorf("from, to both nil failed")
 	}
 
-	res, err = get_range(obj1, -2, nil)
+	res, err = get_range(obj1, -2, nil, nil)
 	t.Logf("err: %v, res:%v", err, res)
 	if res.([]interface{})[0] != 4 || res.([]interface{})[1] != 5 {
 		t.Errorf("from support nil failed: %v", res)
 	}
 
 	obj2 := 2
-	res, err = get_range(obj2, 0, 1)
+	res, err = get_range(obj2, 0, 1, nil)
 	fmt.Println(err, res)
 	if err == nil {
 		t.Errorf("object is Slice error not raised")
 	}
 }
 
-func Test_jsonpath_types_eval(t *testing.T) {
-	fset := token.NewFileSet()
-	res, err := types.Eval(fset, nil, 0, "1 < 2")
-	fmt.Println(err, res, res.Type, res.Value, res.IsValue())
-}
-
 var tcase_parse_filter = []map[string]interface{}{
 	// 0
 	map[string]interface{}{
-		"filter":  "@.isbn",
-		"exp_lp":  "@.isbn",
-		"exp_op":  "exists",
-		"exp_rp":  "",
-		"exp_err": nil,
+		"filter": "@.isbn",
+		"exp":    "@.isbn",
 	},
 	// 1
 	map[string]interface{}{
-		"filter":  "@.price < 10",
-		"exp_lp":  "@.price",
-		"exp_op":  "<",
-		"exp_rp":  "10",
-		"exp_err": nil,
+		"filter": "@.price < 10",
+		"exp":    "@.price < 10",
 	},
 	// 2
 	map[string]interface{}{
-		"filter":  "@.price <= $.expensive",
-		"exp_lp":  "@.price",
-		"exp_op":  "<=",
-		"exp_rp":  "$.expensive",
-		"exp_err": nil,
+		"filter": "@.price <= $.expensive",
+		"exp":    "@.price <= $.expensive",
 	},
 	// 3
 	map[string]interface{}{
-		"filter":  "@.author =~ /.*REES/i",
-		"exp_lp":  "@.author",
-		"exp_op":  "=~",
-		"exp_rp":  "/.*REES/i",
-		"exp_err": nil,
+		"filter": "@.author =~ /.*REES/i",
+		"exp":    "@.author =~ /(?i).*REES/",
 	},
-
 	// 4
 	{
 		"filter": "@.author == 'Nigel Rees'",
-		"exp_lp": "@.author",
-		"exp_op": "==",
-		"exp_rp": "Nigel Rees",
+		"exp":    "@.author == 'Nigel Rees'",
 	},
-}
-
-func Test_jsonpath_parse_filter(t *testing.T) {
-
-	//for _, tcase := range tcase_parse_filter[4:] {
-	for _, tcase := range tcase_parse_filter {
-		lp, op, rp, _ := parse_filter(tcase["filter"].(string))
-		t.Log(tcase)
-		t.Logf("lp: %v, op: %v, rp: %v", lp, op, rp)
-		if lp != tcase["exp_lp"].(string) {
-			t.Errorf("%s(got) != %v(exp_lp)", lp, tcase["exp_lp"])
-			return
-		}
-		if op != tcase["exp_op"].(string) {
-			t.Errorf("%s(got) != %v(exp_op)", op, tcase["exp_op"])
-			return
-		}
-		if rp != tcase["exp_rp"].(string) {
-			t.Errorf("%s(got) != %v(exp_rp)", rp, tcase["exp_rp"])
-			return
-		}
-	}
-}
-
-var tcase_filter_get_from_explicit_path = []map[string]interface{}{
-	// 0
-	map[string]interface{}{
-		// 0 {"a": 1}
-		"obj":      map[string]interface{}{"a": 1},
-		"query":    "$.a",
-		"expected": 1,
+	// 5
+	{
+		"filter": "@.price < 10 && @.category == 'fiction'",
+		"exp":    "@.price < 10 && @.category == 'fiction'",
 	},
-	map[string]interface{}{
-		// 1 {"a":{"b":1}}
-		"obj":      map[string]interface{}{"a": map[string]interface{}{"b": 1}},
-		"query":    "$.a.b",
-		"expected": 1,
+	// 6 && 优先于 ||
+	{
+		"filter": "@.a || @.b && @.c",
+		"exp":    "@.a || @.b && @.c",
 	},
-	map[string]interface{}{
-		// 2 {"a": {"b":1, "c":2}}
-		"obj":      map[string]interface{}{"a": map[string]interface{}{"b": 1, "c": 2}},
-		"query":    "$.a.c",
-		"expected": 2,
+	// 7
+	{
+		"filter": "( @.a || @.b ) && @.c",
+		"exp":    "(@.a || @.b) && @.c",
 	},
-	map[string]interface{}{
-		// 3 {"a": {"b":1}, "b": 2}
-		"obj":      map[string]interface{}{"a": map[string]interface{}{"b": 1}, "b": 2},
-		"query":    "$.a.b",
-		"expected": 1,
+	// 8
+	{
+		"filter": "!(@.a == 1) || !@.b",
+		"exp":    "!(@.a == 1) || !@.b",
 	},
-	map[string]interface{}{
-		// 4 {"a": {"b":1}, "b": 2}
-		"obj":      map[string]interface{}{"a": map[string]interface{}{"b": 1}, "b": 2},
-		"query":    "$.b",
-		"expected": 2,
+	// 9
+	{
+		"filter": "@.category in {fiction, 'poem', 1}",
+		"exp":    "@.category in {'fiction', 'poem', 1}",
 	},
-	map[string]interface{}{
-		// 5 {'a': ['b',1]}
-		"obj":      map[string]interface{}{"a": []interface{}{"b", 1}},
-		"query":    "$.a[0]",
-		"expected": "b",
+	// 10
+	{
+		"filter": "@['a-b'][0] != null",
+		"exp":    "@['a-b'][0] != null",
 	},
 }
 
-func Test_jsonpath_filter_get_from_explicit_path(t *testing.T) {
-
-	for idx, tcase := range tcase_filter_get_from_explicit_path {
-		obj := tcase["obj"]
-		query := tcase["query"].(string)
-		expected := tcase["expected"]
+var tcase_parse_filter_error = []string{
+	"@.a &&",
+	"(@.a || @.b",
+	"@.a == 1 @.b",
+	"!@.a == 1",
+	"@.a =~ 'x'",
+	"@.a in {@.b}",
+	"length(@.a) == 1",
+}
 
-		res, err := filter_get_from_explicit_path(obj, query)
-		t.Log(idx, err, res)
+func Test_jsonpath_parse_filter(t *testing.T) {
+	for idx, tcase := range tcase_parse_filter {
+		c, err := Compile("$[?(" + tcase["filter"].(string) + ")]")
 		if err != nil {
-			t.Errorf("flatten_cases: failed: [%d] %v", idx, err)
-		}
-		// t.Logf("typeof(res): %v, typeof(expected): %v", reflect.TypeOf(res), reflect.TypeOf(expected))
-		if reflect.TypeOf(res) != reflect.TypeOf(expected) {
-			t.Errorf("different type: (res)%v != (expected)%v", reflect.TypeOf(res), reflect.TypeOf(expected))
+			t.Errorf("idx: %d, failed to parse %s: %v", idx, tcase["filter"], err)
 			continue
 		}
-		switch expected.(type) {
-		case map[string]interface{}:
-			if len(res.(map[string]interface{})) != len(expected.(map[string]interface{})) {
-				t.Errorf("two map with differnt lenght: (res)%v, (expected)%v", res, expected)
-			}
-		default:
-			if res != expected {
-				t.Errorf("res(%v) != expected(%v)", res, expected)
-			}
+		got := c.steps[0].args.(*filterExpr).String()
+		if got != tcase["exp"].(string) {
+			t.Errorf("idx: %d, %s(got) != %v(exp)", idx, got, tcase["exp"])
+		}
+	}
+	for idx, filter := range tcase_parse_filter_error {
+		_, err := Compile("$[?(" + filter + ")]")
+		if _, ok := err.(*SyntaxError); ok != true {
+			t.Errorf("idx: %d, %s should return *SyntaxError, got: %v", idx, filter, err)
 		}
 	}
 }
@@ -715,81 +718,125 @@ func Test_jsonpath_filter_get_from_explicit_path(t *testing.T) {
 var tcase_eval_filter = []map[string]interface{}{
 	// 0
 	map[string]interface{}{
-		"obj":  map[string]interface{}{"a": 1},
-		"root": map[string]interface{}{},
-		"lp":   "@.a",
-		"op":   "exists",
-		"rp":   "",
-		"exp":  true,
+		"obj":    map[string]interface{}{"a": 1},
+		"root":   map[string]interface{}{},
+		"filter": "@.a",
+		"exp":    true,
 	},
 	// 1
 	map[string]interface{}{
-		"obj":  map[string]interface{}{"a": 1},
-		"root": map[string]interface{}{},
-		"lp":   "@.b",
-		"op":   "exists",
-		"rp":   "",
-		"exp":  false,
+		"obj":    map[string]interface{}{"a": 1},
+		"root":   map[string]interface{}{},
+		"filter": "@.b",
+		"exp":    false,
 	},
 	// 2
 	map[string]interface{}{
-		"obj":  map[string]interface{}{"a": 1},
-		"root": map[string]interface{}{"a": 1},
-		"lp":   "$.a",
-		"op":   "exists",
-		"rp":   "",
-		"exp":  true,
+		"obj":    map[string]interface{}{"a": 1},
+		"root":   map[string]interface{}{"a": 1},
+		"filter": "$.a",
+		"exp":    true,
 	},
 	// 3
 	map[string]interface{}{
-		"obj":  map[string]interface{}{"a": 1},
-		"root": map[string]interface{}{"a": 1},
-		"lp":   "$.b",
-		"op":   "exists",
-		"rp":   "",
-		"exp":  false,
+		"obj":    map[string]interface{}{"a": 1},
+		"root":   map[string]interface{}{"a": 1},
+		"filter": "$.b",
+		"exp":    false,
 	},
 	// 4
 	map[string]interface{}{
-		"obj":  map[string]interface{}{"a": 1, "b": map[string]interface{}{"c": 2}},
-		"root": map[string]interface{}{"a": 1, "b": map[string]interface{}{"c": 2}},
-		"lp":   "$.b.c",
-		"op":   "exists",
-		"rp":   "",
-		"exp":  true,
+		"obj":    map[string]interface{}{"a": 1, "b": map[string]interface{}{"c": 2}},
+		"root":   map[string]interface{}{"a": 1, "b": map[string]interface{}{"c": 2}},
+		"filter": "$.b.c",
+		"exp":    true,
 	},
 	// 5
 	map[string]interface{}{
-		"obj":  map[string]interface{}{"a": 1, "b": map[string]interface{}{"c": 2}},
-		"root": map[string]interface{}{},
-		"lp":   "$.b.a",
-		"op":   "exists",
-		"rp":   "",
-		"exp":  false,
+		"obj":    map[string]interface{}{"a": 1, "b": map[string]interface{}{"c": 2}},
+		"root":   map[string]interface{}{},
+		"filter": "$.b.a",
+		"exp":    false,
 	},
 
 	// 6
 	map[string]interface{}{
-		"obj":  map[string]interface{}{"a": 3},
-		"root": map[string]interface{}{"a": 3},
-		"lp":   "$.a",
-		"op":   ">",
-		"rp":   "1",
-		"exp":  true,
+		"obj":    map[string]interface{}{"a": 3},
+		"root":   map[string]interface{}{"a": 3},
+		"filter": "$.a > 1",
+		"exp":    true,
+	},
+	// 7
+	map[string]interface{}{
+		"obj":    map[string]interface{}{"a": 1, "b": "x"},
+		"root":   map[string]interface{}{},
+		"filter": "@.a == 1 && @.b == 'x'",
+		"exp":    true,
+	},
+	// 8
+	map[string]interface{}{
+		"obj":    map[string]interface{}{"a": 1, "b": "x"},
+		"root":   map[string]interface{}{},
+		"filter": "@.a == 2 || !(@.b == 'y')",
+		"exp":    true,
+	},
+	// 9
+	map[string]interface{}{
+		"obj":    map[string]interface{}{"a": 1, "b": "x"},
+		"root":   map[string]interface{}{},
+		"filter": "@.a == 1 || @.b == 'x' && @.c",
+		"exp":    true,
+	},
+	// 10
+	map[string]interface{}{
+		"obj":    map[string]interface{}{"a": 1, "b": "x"},
+		"root":   map[string]interface{}{},
+		"filter": "(@.a == 1 || @.b == 'x') && @.c",
+		"exp":    false,
+	},
+	// 11 不存在的值只满足 !=
+	map[string]interface{}{
+		"obj":    map[string]interface{}{"a": 1},
+		"root":   map[string]interface{}{},
+		"filter": "@.c < 1 || @.c > 1 || @.c == 1",
+		"exp":    false,
+	},
+	// 12
+	map[string]interface{}{
+		"obj":    map[string]interface{}{"a": 1},
+		"root":   map[string]interface{}{},
+		"filter": "@.c != 1",
+		"exp":    true,
+	},
+	// 13
+	map[string]interface{}{
+		"obj":    map[string]interface{}{"b": "x"},
+		"root":   map[string]interface{}{},
+		"filter": "@.b in {x, y} && @.b noin {z}",
+		"exp":    true,
+	},
+	// 14
+	map[string]interface{}{
+		"obj":    map[string]interface{}{"b": "Nigel Rees"},
+		"root":   map[string]interface{}{},
+		"filter": "@.b =~ /rees$/i && !(@.b =~ /^Rees/)",
+		"exp":    true,
 	},
 }
 
 func Test_jsonpath_eval_filter(t *testing.T) {
-	for idx, tcase := range tcase_eval_filter[1:] {
-		fmt.Println("------------------------------")
+	for idx, tcase := range tcase_eval_filter {
 		obj := tcase["obj"].(map[string]interface{})
 		root := tcase["root"].(map[string]interface{})
-		lp := tcase["lp"].(string)
-		op := tcase["op"].(string)
-		rp := tcase["rp"].(string)
+		filter := tcase["filter"].(string)
 		exp := tcase["exp"].(bool)
-		t.Logf("idx: %v, lp: %v, op: %v, rp: %v, exp: %v", idx, lp, op, rp, exp)
-		got, err := eval_filter(obj, root, lp, op, rp)
+		t.Logf("idx: %v, filter: %v, exp: %v", idx, filter, exp)
+		c, err := Compile("$[?(" + filter + ")]")
+		if err != nil {
+			t.Errorf("idx: %v, failed to parse: %v", idx, err)
+			continue
+		}
+		got, err := eval_filter(obj, root, c.steps[0].args.(*filterExpr))
 
 		if err != nil {
 			t.Errorf("idx: %v, failed to eval: %v", idx, err)
@@ -867,9 +914,106 @@ var tcase_cmp_any = []map[string]interface{}{
 		"op":   ">",
 		"exp":  false,
 		"err":  nil,
+	}, {
+		"obj1": `a"b\\`,
+		"obj2": `a"b\\`,
+		"op":   "==",
+		"exp":  true,
+		"err":  nil,
+	}, {
+		"obj1": "1",
+		"obj2": 1,
+		"op":   "==",
+		"exp":  false,
+		"err":  nil,
+	}, {
+		"obj1": "1",
+		"obj2": 1,
+		"op":   "!=",
+		"exp":  true,
+		"err":  nil,
+	}, {
+		"obj1": 1,
+		"obj2": 1.0,
+		"op":   "==",
+		"exp":  true,
+		"err":  nil,
+	}, {
+		"obj1": "10",
+		"obj2": "9",
+		"op":   "<",
+		"exp":  true,
+		"err":  nil,
+	}, {
+		"obj1": true,
+		"obj2": false,
+		"op":   ">",
+		"exp":  false,
+		"err":  nil,
+	}, {
+		"obj1": nil,
+		"obj2": nil,
+		"op":   "<=",
+		"exp":  true,
+		"err":  nil,
+	}, {
+		"obj1": nil,
+		"obj2": false,
+		"op":   "==",
+		"exp":  false,
+		"err":  nil,
+	}, {
+		"obj1": []interface{}{1, map[string]interface{}{"a": "x"}},
+		"obj2": []interface{}{1.0, map[string]interface{}{"a": "x"}},
+		"op":   "==",
+		"exp":  true,
+		"err":  nil,
+	}, {
+		"obj1": map[string]interface{}{"a": 1, "b": 2},
+		"obj2": map[string]interface{}{"a": 1},
+		"op":   "==",
+		"exp":  false,
+		"err":  nil,
+	}, {
+		"obj1": []interface{}{1},
+		"obj2": []interface{}{2},
+		"op":   "<",
+		"exp":  false,
+		"err":  nil,
 	},
 }
 
+func Test_jsonpath_numeric_string_coercion(t *testing.T) {
+	var data interface{}
+	json.Unmarshal([]byte(`[{"a":"10"},{"a":9},{"a":"x"},{"a":"1e1"}]`), &data)
+	tcases := []struct {
+		Query  string
+		Coerce bool
+		Expect []interface{}
+	}{
+		{"$[?(@.a == 10)].a", false, []interface{}{}},
+		{"$[?(@.a == 10)].a", true, []interface{}{"10", "1e1"}},
+		{"$[?(@.a > 9)].a", false, []interface{}{}},
+		{"$[?(@.a > 9)].a", true, []interface{}{"10", "1e1"}},
+		{"$[?(@.a < '9')].a", false, []interface{}{"10", "1e1"}},
+		{"$[?(@.a in {9, 10})].a", true, []interface{}{"10", 9.0, "1e1"}},
+	}
+	for idx, tcase := range tcases {
+		var opts []Option
+		if tcase.Coerce {
+			opts = append(opts, WithNumericStringCoercion())
+		}
+		res, err := MustCompile(tcase.Query, opts...).Lookup(data)
+		if err != nil {
+			t.Errorf("idx: %d, query: %s, error: %v", idx, tcase.Query, err)
+			continue
+		}
+		if !reflect.DeepEqual(res, tcase.Expect) {
+			t.Errorf("idx: %d, query: %s, (got)%v != (exp)%v", idx, tcase.Query, res, tcase.Expect)
+		}
+	}
+}
+
 func Test_jsonpath_cmp_any(t *testing.T) {
 	for idx, tcase := range tcase_cmp_any {
 		//for idx, tcase := range tcase_cmp_any[8:] {
@@ -938,7 +1082,7 @@ func Test_jsonpath_string_equal(t *testing.T) {
 
 	json.Unmarshal([]byte(data), &j)
 
-	res, err := JsonPathLookup(j, "$.store.book[?(@.author == 'Nigel Rees')].price")
+	res, err := JsonPathLookUp(j, "$.store.book[?(@.author == 'Nigel Rees')].price")
 	t.Log(res, err)
 	if err != nil {
 		t.Fatalf("err: %v", err)
@@ -958,10 +1102,73 @@ func Test_jsonpath_null_in_the_middle(t *testing.T) {
 
 	json.Unmarshal([]byte(data), &j)
 
-	res, err := JsonPathLookup(j, "$.head_commit.author.username")
+	res, err := JsonPathLookUp(j, "$.head_commit.author.username")
 	t.Log(res, err)
 }
 
+//过滤条件中的路径遇到null或不存在的值时视为没有取到值，比较不成立
+func Test_jsonpath_filter_null_members(t *testing.T) {
+	var j interface{}
+	json.Unmarshal([]byte(`{"users": [{"id": 1, "tags": ["vip"]}, {"id": 2, "tags": null}, {"id": 3}, {"id": 4, "tags": []}, {"id": 5, "tags": {"a": 1}}, null]}`), &j)
+	tcases := []struct {
+		Path   string
+		Expect []interface{}
+	}{
+		{"$.users[?(@.tags[0] == 'vip')].id", []interface{}{1.0}},
+		{"$.users[?(@.tags[0] != 'vip')].id", []interface{}{2.0, 3.0, 4.0, 5.0}},
+		{"$.users[?(@.tags[0:1] == 'vip')].id", []interface{}{}},
+		{"$.users[?(@.tags[0])].id", []interface{}{1.0}},
+		{"$.users[?(@.tags.a == 1)].id", []interface{}{5.0}},
+	}
+	for idx, tcase := range tcases {
+		res, err := JsonPathLookUp(j, tcase.Path)
+		if err != nil || !reflect.DeepEqual(res, tcase.Expect) {
+			t.Errorf("idx: %d, path: %s, (got)%v != (exp)%v, err: %v", idx, tcase.Path, res, tcase.Expect, err)
+		}
+	}
+	if _, err := get_idx(nil, 0); err != ErrGetFromNullObj {
+		t.Errorf("get_idx: %v", err)
+	}
+	if _, err := get_range(nil, 0, nil, nil); err != ErrGetFromNullObj {
+		t.Errorf("get_range: %v", err)
+	}
+	if _, err := get_filtered(nil, nil, nil, false); err != ErrGetFromNullObj {
+		t.Errorf("get_filtered: %v", err)
+	}
+}
+
+//数组元素类型不同时，数组上取键值得到的空列表视为不存在
+func Test_jsonpath_filter_exists_mixed(t *testing.T) {
+	doc := `{"a": [[1, 2], {"name": 1}, [], "s", {"name": null}, [{"name": 2}], {"tags": []}]}`
+	tcases := []struct {
+		Path   string
+		Expect string
+	}{
+		{"$.a[?(@.name)]", `[{"name": 1}, [{"name": 2}]]`},
+		{"$.a[?(!@.name)]", `[[1, 2], [], "s", {"name": null}, {"tags": []}]`},
+		{"$.a[?(@.tags)]", `[{"tags": []}]`},
+		{"$.a[?(@[0])]", `[[1, 2], [{"name": 2}]]`},
+		{"$.a[?(@.*)]", `[[1, 2], {"name": 1}, {"name": null}, [{"name": 2}], {"tags": []}]`},
+	}
+	for idx, tcase := range tcases {
+		var j, expect interface{}
+		json.Unmarshal([]byte(doc), &j)
+		json.Unmarshal([]byte(tcase.Expect), &expect)
+		res, err := JsonPathLookUp(j, tcase.Path)
+		if err != nil || !reflect.DeepEqual(res, expect) {
+			t.Errorf("idx: %d, path: %s, (got)%v != (exp)%v, err: %v", idx, tcase.Path, toString(res), tcase.Expect, err)
+		}
+	}
+
+	var j, expect interface{}
+	json.Unmarshal([]byte(doc), &j)
+	json.Unmarshal([]byte(`{"a": [[1, 2], [], "s", {"name": null}, {"tags": []}]}`), &expect)
+	res, err := JsonPathLookUpAndDel(j, "$.a[?(@.name)]")
+	if err != nil || !reflect.DeepEqual(res, expect) {
+		t.Errorf("(got)%v != (exp)%v, err: %v", toString(res), toString(expect), err)
+	}
+}
+
 func Test_jsonpath_num_cmp(t *testing.T) {
 	data := `{
 	"books": [ 
@@ -971,7 +1178,7 @@ func Test_jsonpath_num_cmp(t *testing.T) {
 }`
 	var j interface{}
 	json.Unmarshal([]byte(data), &j)
-	res, err := JsonPathLookup(j, "$.books[?(@.price > 100)].name")
+	res, err := JsonPathLookUp(j, "$.books[?(@.price > 100)].name")
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -1000,7 +1207,7 @@ func BenchmarkJsonPathLookupCompiled(b *testing.B) {
 
 func BenchmarkJsonPathLookup(b *testing.B) {
 	for n := 0; n < b.N; n++ {
-		res, err := JsonPathLookup(json_data, "$.store.book[0].price")
+		res, err := JsonPathLookUp(json_data, "$.store.book[0].price")
 		if res_v, ok := res.(float64); ok != true || res_v != 8.95 {
 			b.Errorf("$.store.book[0].price should be 8.95")
 		}
@@ -1012,67 +1219,81 @@ func BenchmarkJsonPathLookup(b *testing.B) {
 
 func BenchmarkJsonPathLookup_0(b *testing.B) {
 	for i := 0; i < b.N; i++ {
-		JsonPathLookup(json_data, "$.expensive")
+		JsonPathLookUp(json_data, "$.expensive")
 	}
 }
 
 func BenchmarkJsonPathLookup_1(b *testing.B) {
 	for i := 0; i < b.N; i++ {
-		JsonPathLookup(json_data, "$.store.book[0].price")
+		JsonPathLookUp(json_data, "$.store.book[0].price")
 	}
 }
 
 func BenchmarkJsonPathLookup_2(b *testing.B) {
 	for i := 0; i < b.N; i++ {
-		JsonPathLookup(json_data, "$.store.book[-1].price")
+		JsonPathLookUp(json_data, "$.store.book[-1].price")
 	}
 }
 
 func BenchmarkJsonPathLookup_3(b *testing.B) {
 	for i := 0; i < b.N; i++ {
-		JsonPathLookup(json_data, "$.store.book[0,1].price")
+		JsonPathLookUp(json_data, "$.store.book[0,1].price")
 	}
 }
 
 func BenchmarkJsonPathLookup_4(b *testing.B) {
 	for i := 0; i < b.N; i++ {
-		JsonPathLookup(json_data, "$.store.book[0:2].price")
+		JsonPathLookUp(json_data, "$.store.book[0:2].price")
 	}
 }
 
 func BenchmarkJsonPathLookup_5(b *testing.B) {
 	for i := 0; i < b.N; i++ {
-		JsonPathLookup(json_data, "$.store.book[?(@.isbn)].price")
+		JsonPathLookUp(json_data, "$.store.book[?(@.isbn)].price")
 	}
 }
 
 func BenchmarkJsonPathLookup_6(b *testing.B) {
 	for i := 0; i < b.N; i++ {
-		JsonPathLookup(json_data, "$.store.book[?(@.price > 10)].title")
+		JsonPathLookUp(json_data, "$.store.book[?(@.price > 10)].title")
 	}
 }
 
 func BenchmarkJsonPathLookup_7(b *testing.B) {
 	for i := 0; i < b.N; i++ {
-		JsonPathLookup(json_data, "$.store.book[?(@.price < $.expensive)].price")
+		JsonPathLookUp(json_data, "$.store.book[?(@.price < $.expensive)].price")
 	}
 }
 
 func BenchmarkJsonPathLookup_8(b *testing.B) {
 	for i := 0; i < b.N; i++ {
-		JsonPathLookup(json_data, "$.store.book[:].price")
+		JsonPathLookUp(json_data, "$.store.book[:].price")
 	}
 }
 
 func BenchmarkJsonPathLookup_9(b *testing.B) {
 	for i := 0; i < b.N; i++ {
-		JsonPathLookup(json_data, "$.store.book[?(@.author == 'Nigel Rees')].price")
+		JsonPathLookUp(json_data, "$.store.book[?(@.author == 'Nigel Rees')].price")
 	}
 }
 
 func BenchmarkJsonPathLookup_10(b *testing.B) {
 	for i := 0; i < b.N; i++ {
-		JsonPathLookup(json_data, "$.store.book[?(@.author =~ /(?i).*REES/)].price")
+		JsonPathLookUp(json_data, "$.store.book[?(@.author =~ /(?i).*REES/)].price")
+	}
+}
+
+func BenchmarkJsonPathLookup_11(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		JsonPathLookUp(json_data, "$.store.book[?(@.price > 10 && @.category == 'fiction')].title")
+	}
+}
+
+func BenchmarkJsonPathCmpAny(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		cmp_any(8.95, "8.95", "==")
+		cmp_any(8.95, 10, "<")
+		cmp_any("Nigel Rees", "Evelyn Waugh", ">=")
 	}
 }
 
@@ -1081,7 +1302,7 @@ func TestReg(t *testing.T) {
 	t.Log(r)
 	t.Log(r.Match([]byte(`Nigel Rees`)))
 
-	res, err := JsonPathLookup(json_data, "$.store.book[?(@.author =~ /(?i).*REES/ )].author")
+	res, err := JsonPathLookUp(json_data, "$.store.book[?(@.author =~ /(?i).*REES/ )].author")
 	t.Log(err, res)
 
 	author := res.([]interface{})[0].(string)
@@ -1139,7 +1360,7 @@ func Test_jsonpath_rootnode_is_array(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	res, err := JsonPathLookup(j, "$[0].test")
+	res, err := JsonPathLookUp(j, "$[0].test")
 	t.Log(res, err)
 	if err != nil {
 		t.Fatal("err:", err)
@@ -1166,7 +1387,7 @@ func Test_jsonpath_rootnode_is_array_range(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	res, err := JsonPathLookup(j, "$[:1].test")
+	res, err := JsonPathLookUp(j, "$[:1].test")
 	t.Log(res, err)
 	if err != nil {
 		t.Fatal("err:", err)
@@ -1179,13 +1400,13 @@ func Test_jsonpath_rootnode_is_array_range(t *testing.T) {
 		t.Logf("idx: %v, v: %v", idx, v)
 	}
 	if len(ares) != 2 {
-		t.Fatal("len is not 2. got: %v", len(ares))
+		t.Fatalf("len is not 2. got: %v", len(ares))
 	}
 	if ares[0].(float64) != 12.34 {
-		t.Fatal("idx: 0, should be 12.34. got: %v", ares[0])
+		t.Fatalf("idx: 0, should be 12.34. got: %v", ares[0])
 	}
 	if ares[1].(float64) != 13.34 {
-		t.Fatal("idx: 0, should be 12.34. got: %v", ares[1])
+		t.Fatalf("idx: 0, should be 12.34. got: %v", ares[1])
 	}
 }
 
@@ -1199,7 +1420,7 @@ func Test_jsonpath_rootnode_is_nested_array(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	res, err := JsonPathLookup(j, "$[0].[0].test")
+	res, err := JsonPathLookUp(j, "$[0].[0].test")
 	t.Log(res, err)
 	if err != nil {
 		t.Fatal("err:", err)
@@ -1219,7 +1440,7 @@ func Test_jsonpath_rootnode_is_nested_array_range(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	res, err := JsonPathLookup(j, "$[:1].[0].test")
+	res, err := JsonPathLookUp(j, "$[:1].[0].test")
 	t.Log(res, err)
 	if err != nil {
 		t.Fatal("err:", err)
@@ -1232,14 +1453,485 @@ func Test_jsonpath_rootnode_is_nested_array_range(t *testing.T) {
 		t.Logf("idx: %v, v: %v", idx, v)
 	}
 	if len(ares) != 2 {
-		t.Fatal("len is not 2. got: %v", len(ares))
+		t.Fatalf("len is not 2. got: %v", len(ares))
 	}
 
 	//FIXME: `$[:1].[0].test` got wrong result
 	//if ares[0].(float64) != 1.1 {
-	//	t.Fatal("idx: 0, should be 1.1, got: %v", ares[0])
+	//	t.Fatalf("idx: 0, should be 1.1, got: %v", ares[0])
 	//}
 	//if ares[1].(float64) != 3.1 {
-	//	t.Fatal("idx: 0, should be 3.1, got: %v", ares[1])
+	//	t.Fatalf("idx: 0, should be 3.1, got: %v", ares[1])
 	//}
 }
+
+func Test_jsonpath_bracket_names_and_union(t *testing.T) {
+	var j interface{}
+	json.Unmarshal([]byte(`{"a": 1, "b": 2, "a.b": 3, "it's": 4, "用户 名": "张三", "tab\there": 5, "list": [10, 20, 30]}`), &j)
+
+	tcases := []struct {
+		Path string
+		Exp  string
+	}{
+		{`$['a']`, "1"},
+		{`$["a.b"]`, "3"},
+		{`$['it\'s']`, "4"},
+		{`$["it's"]`, "4"},
+		{`$['用户 名']`, "张三"},
+		{`$["\u7528\u6237 \u540d"]`, "张三"},
+		{`$['tab\there']`, "5"},
+		{`$['a','b']`, "[1 2]"},
+		{`$['a','missing','a.b']`, "[1 3]"},
+		{`$.list[0,'a',-1]`, "[10 30]"},
+		{`$['list'][1]`, "20"},
+	}
+	for idx, tcase := range tcases {
+		res, err := JsonPathLookUp(j, tcase.Path)
+		t.Log(idx, tcase.Path, res, err)
+		if err != nil {
+			t.Errorf("idx: %d, %s failed: %v", idx, tcase.Path, err)
+			continue
+		}
+		if fmt.Sprintf("%v", res) != tcase.Exp {
+			t.Errorf("idx: %d, %s: (got)%v != (exp)%v", idx, tcase.Path, res, tcase.Exp)
+		}
+	}
+
+	_, err := Compile(`$['a\x']`)
+	if err == nil {
+		t.Errorf("invalid escape should fail")
+	}
+	_, err = Compile(`$['a',?(@.b)]`)
+	if err == nil {
+		t.Errorf("filter in union should fail")
+	}
+}
+
+func Test_jsonpath_union_operate(t *testing.T) {
+	var j interface{}
+	json.Unmarshal([]byte(`{"user": {"phone": "13800138000", "id card": "110101199003071234", "name": "张三"}, "list": [1, 2, 3, 4]}`), &j)
+
+	_, err := JsonPathLookUpAndDel(j, `$.user['phone','id card']`)
+	if err != nil {
+		t.Fatalf("failed to delete: %v", err)
+	}
+	user := j.(map[string]interface{})["user"].(map[string]interface{})
+	if len(user) != 1 || user["name"] != "张三" {
+		t.Errorf("phone and id card should be deleted, got: %v", user)
+	}
+
+	_, err = JsonPathLookUpAndDel(j, `$.list[0,'x',2]`)
+	if err != nil {
+		t.Fatalf("failed to delete: %v", err)
+	}
+	if fmt.Sprintf("%v", j.(map[string]interface{})["list"]) != "[2 4]" {
+		t.Errorf("list should be [2 4], got: %v", j.(map[string]interface{})["list"])
+	}
+
+	json.Unmarshal([]byte(`{"user": {"phone": "13800138000", "名字": "张三丰"}, "list": [{"x": 1}, 2]}`), &j)
+	_, err = JsonPathLookUpAndDel(j, `$.list['x','y']`)
+	if err != nil || fmt.Sprintf("%v", j.(map[string]interface{})["list"]) != "[map[] 2]" {
+		t.Errorf("x should be deleted from list elements, got: %v, %v", j.(map[string]interface{})["list"], err)
+	}
+	_, err = JsonPathLookUpAndDesensitization(j, `$.user['phone']`, PhoneDesensitization)
+	if err != nil {
+		t.Fatalf("failed to desensitize: %v", err)
+	}
+	_, err = JsonPathLookUpAndDesensitization(j, `$['user']['名字']`, NameDesensitization)
+	if err != nil {
+		t.Fatalf("failed to desensitize: %v", err)
+	}
+	user = j.(map[string]interface{})["user"].(map[string]interface{})
+	if user["phone"] != "138****8000" || user["名字"] != "张*丰" {
+		t.Errorf("desensitization failed: %v", user)
+	}
+}
+
+func Test_jsonpath_range_step(t *testing.T) {
+	var j interface{}
+	json.Unmarshal([]byte(`{"a":[0,1,2,3,4,5]}`), &j)
+	tcases := []struct {
+		Path   string
+		Expect []interface{}
+	}{
+		{"$.a[::2]", []interface{}{0.0, 2.0, 4.0}},
+		{"$.a[1::2]", []interface{}{1.0, 3.0, 5.0}},
+		{"$.a[::-1]", []interface{}{5.0, 4.0, 3.0, 2.0, 1.0, 0.0}},
+		{"$.a[4:1:-2]", []interface{}{4.0, 2.0}},
+		{"$.a[-1:0:-1]", []interface{}{5.0, 4.0, 3.0, 2.0, 1.0, 0.0}},
+		{"$.a[1:3]", []interface{}{1.0, 2.0, 3.0}},
+		{"$.a[4:100]", []interface{}{4.0, 5.0}},
+		{"$.a[-100:1]", []interface{}{0.0, 1.0}},
+		{"$.a[3:1]", []interface{}{}},
+		{"$.a[::0]", []interface{}{}},
+	}
+	for idx, tcase := range tcases {
+		res, err := JsonPathLookUp(j, tcase.Path)
+		if err != nil {
+			t.Errorf("idx: %d, path: %s, err: %v", idx, tcase.Path, err)
+			continue
+		}
+		if !reflect.DeepEqual(res, tcase.Expect) {
+			t.Errorf("idx: %d, path: %s, (got)%v != (exp)%v", idx, tcase.Path, res, tcase.Expect)
+		}
+	}
+}
+
+func Test_jsonpath_range_step_scan_and_operate(t *testing.T) {
+	var j interface{}
+	json.Unmarshal([]byte(`[{"phone":"1"},{"phone":"2"},{"phone":"3"},{"phone":"4"}]`), &j)
+	res, err := JsonPathLookUp(j, "$..phone[::-2]")
+	if err != nil || !reflect.DeepEqual(res, []interface{}{"4", "2"}) {
+		t.Errorf("$..phone[::-2] should be [4 2], got: %v, err: %v", res, err)
+	}
+	_, err = JsonPathLookUpAndDel(j, "$..phone[1::2]")
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, _ = JsonPathLookUp(j, "$..phone")
+	if !reflect.DeepEqual(res, []interface{}{"1", "3"}) {
+		t.Errorf("$..phone[1::2] should delete 2nd and 4th phone, got: %v", res)
+	}
+
+	json.Unmarshal([]byte(`{"logs":["a","b","c","d","e"]}`), &j)
+	_, err = JsonPathLookUpAndDel(j, "$.logs[::2]")
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, _ = JsonPathLookUp(j, "$.logs")
+	if !reflect.DeepEqual(res, []interface{}{"b", "d"}) {
+		t.Errorf("$.logs[::2] should delete even indexes, got: %v", res)
+	}
+	_, err = JsonPathLookUpAndDel(j, "$.logs[5:10]")
+	if err != nil {
+		t.Errorf("out of range should be clamped, got err: %v", err)
+	}
+}
+
+func Test_jsonpath_desensitization_array_elements(t *testing.T) {
+	doc := `{"phones": ["13800138000", "13900139000", "13700137000", 1], "users": [{"vip": true, "phone": "13800138000"}, {"vip": false, "phone": "13900139000"}]}`
+	tcases := []struct {
+		Path   string
+		Expect string
+	}{
+		{"$.phones[0]", `{"phones":["138****8000","13900139000","13700137000",1],"users":[{"vip":true,"phone":"13800138000"},{"vip":false,"phone":"13900139000"}]}`},
+		{"$.phones[-2]", `{"phones":["13800138000","13900139000","137****7000",1],"users":[{"vip":true,"phone":"13800138000"},{"vip":false,"phone":"13900139000"}]}`},
+		{"$.phones[1:3]", `{"phones":["13800138000","139****9000","137****7000",1],"users":[{"vip":true,"phone":"13800138000"},{"vip":false,"phone":"13900139000"}]}`},
+		{"$.phones[*]", `{"phones":["138****8000","139****9000","137****7000",1],"users":[{"vip":true,"phone":"13800138000"},{"vip":false,"phone":"13900139000"}]}`},
+		{"$.phones[?(@ =~ /^139/)]", `{"phones":["13800138000","139****9000","13700137000",1],"users":[{"vip":true,"phone":"13800138000"},{"vip":false,"phone":"13900139000"}]}`},
+		{"$.users[?(@.vip == true)].phone", `{"phones":["13800138000","13900139000","13700137000",1],"users":[{"vip":true,"phone":"138****8000"},{"vip":false,"phone":"13900139000"}]}`},
+		{"$.users[?(@.vip == true)]", doc},
+	}
+	for idx, tcase := range tcases {
+		var j, expect interface{}
+		json.Unmarshal([]byte(doc), &j)
+		json.Unmarshal([]byte(tcase.Expect), &expect)
+		res, err := JsonPathLookUpAndDesensitization(j, tcase.Path, PhoneDesensitization)
+		if err != nil || !reflect.DeepEqual(res, expect) {
+			t.Errorf("idx: %d, path: %s, (got)%v != (exp)%v, err: %v", idx, tcase.Path, toString(res), tcase.Expect, err)
+		}
+	}
+
+	var j interface{}
+	json.Unmarshal([]byte(doc), &j)
+	if _, err := JsonPathLookUpAndDel(j, "$.phones[-1]"); err != nil {
+		t.Fatal(err)
+	}
+	if res, _ := JsonPathLookUp(j, "$.phones"); len(res.([]interface{})) != 3 {
+		t.Errorf("$.phones[-1] should delete the last element, got: %v", res)
+	}
+}
+
+//过滤条件在上一步得到的每个数组上分别执行
+func Test_jsonpath_filter_after_list(t *testing.T) {
+	doc := `{"users": [{"phones": [{"t": "home", "n": "13800138000"}, {"t": "work", "n": "13900139000"}]}, {"phones": [{"t": "home", "n": "13700137000"}]}, {"phones": null}, {"name": "x"}]}`
+	tcases := []struct {
+		Path   string
+		Lookup string
+		Del    string
+		Mask   string
+	}{
+		{"$.users[*].phones[?(@.t == 'home')]",
+			`[{"t": "home", "n": "13800138000"}, {"t": "home", "n": "13700137000"}]`,
+			`{"users": [{"phones": [{"t": "work", "n": "13900139000"}]}, {"phones": []}, {"phones": null}, {"name": "x"}]}`,
+			doc},
+		{"$.users.phones[?(@.t == 'home')].n",
+			`["13800138000", "13700137000"]`,
+			`{"users": [{"phones": [{"t": "home"}, {"t": "work", "n": "13900139000"}]}, {"phones": [{"t": "home"}]}, {"phones": null}, {"name": "x"}]}`,
+			`{"users": [{"phones": [{"t": "home", "n": "138****8000"}, {"t": "work", "n": "13900139000"}]}, {"phones": [{"t": "home", "n": "137****7000"}]}, {"phones": null}, {"name": "x"}]}`},
+		{"$.users[*].phones[?(@.t == 'work')].n",
+			`["13900139000"]`,
+			`{"users": [{"phones": [{"t": "home", "n": "13800138000"}, {"t": "work"}]}, {"phones": [{"t": "home", "n": "13700137000"}]}, {"phones": null}, {"name": "x"}]}`,
+			`{"users": [{"phones": [{"t": "home", "n": "13800138000"}, {"t": "work", "n": "139****9000"}]}, {"phones": [{"t": "home", "n": "13700137000"}]}, {"phones": null}, {"name": "x"}]}`},
+		{"$.users[*][?(@.name)].name",
+			`["x"]`,
+			`{"users": [{"phones": [{"t": "home", "n": "13800138000"}, {"t": "work", "n": "13900139000"}]}, {"phones": [{"t": "home", "n": "13700137000"}]}, {"phones": null}, {}]}`,
+			`{"users": [{"phones": [{"t": "home", "n": "13800138000"}, {"t": "work", "n": "13900139000"}]}, {"phones": [{"t": "home", "n": "13700137000"}]}, {"phones": null}, {"name": "x"}]}`},
+	}
+	for idx, tcase := range tcases {
+		var j, expect interface{}
+		json.Unmarshal([]byte(doc), &j)
+		json.Unmarshal([]byte(tcase.Lookup), &expect)
+		res, err := JsonPathLookUp(j, tcase.Path)
+		if err != nil || !reflect.DeepEqual(res, expect) {
+			t.Errorf("idx: %d, lookup: %s, (got)%v != (exp)%v, err: %v", idx, tcase.Path, toString(res), tcase.Lookup, err)
+		}
+		json.Unmarshal([]byte(tcase.Del), &expect)
+		res, err = JsonPathLookUpAndDel(j, tcase.Path)
+		if err != nil || !reflect.DeepEqual(res, expect) {
+			t.Errorf("idx: %d, del: %s, (got)%v != (exp)%v, err: %v", idx, tcase.Path, toString(res), tcase.Del, err)
+		}
+		json.Unmarshal([]byte(doc), &j)
+		json.Unmarshal([]byte(tcase.Mask), &expect)
+		res, err = JsonPathLookUpAndDesensitization(j, tcase.Path, PhoneDesensitization)
+		if err != nil || !reflect.DeepEqual(res, expect) {
+			t.Errorf("idx: %d, mask: %s, (got)%v != (exp)%v, err: %v", idx, tcase.Path, toString(res), tcase.Mask, err)
+		}
+	}
+
+	//根节点是数组
+	var j, expect interface{}
+	json.Unmarshal([]byte(`[{"p": "13800138000"}, {"q": 1}, "s"]`), &j)
+	json.Unmarshal([]byte(`[{"q": 1}, "s"]`), &expect)
+	if res, err := JsonPathLookUpAndDel(j, "$[?(@.p)]"); err != nil || !reflect.DeepEqual(res, expect) {
+		t.Errorf("$[?(@.p)]: (got)%v, err: %v", toString(res), err)
+	}
+	json.Unmarshal([]byte(`[{"p": "13800138000"}, {"q": 1}, "s"]`), &j)
+	json.Unmarshal([]byte(`[{"p": "138****8000"}, {"q": 1}, "s"]`), &expect)
+	if res, err := JsonPathLookUpAndDesensitization(j, "$[?(@.p)].p", PhoneDesensitization); err != nil || !reflect.DeepEqual(res, expect) {
+		t.Errorf("$[?(@.p)].p: (got)%v, err: %v", toString(res), err)
+	}
+	//不是数组或对象时返回错误
+	json.Unmarshal([]byte(`{"a": "x"}`), &j)
+	if _, err := JsonPathLookUpAndDel(j, "$.a[?(@.p)]"); err == nil {
+		t.Errorf("filter on string error not raised")
+	}
+}
+
+func Test_jsonpath_wildcard(t *testing.T) {
+	var j interface{}
+	json.Unmarshal([]byte(`{"user": {"b": 2, "a": 1}, "list": [{"x": 1, "y": 2}, {"x": 3}], "deep": {"p": {"q": [5, 6]}}}`), &j)
+	tcases := []struct {
+		Path   string
+		Expect interface{}
+	}{
+		{"$.user.*", []interface{}{1.0, 2.0}},
+		{"$.user[*]", []interface{}{1.0, 2.0}},
+		{"$.list[*].x", []interface{}{1.0, 3.0}},
+		{"$.list[*].*", []interface{}{1.0, 2.0, 3.0}},
+		{"$.list.*", []interface{}{map[string]interface{}{"x": 1.0, "y": 2.0}, map[string]interface{}{"x": 3.0}}},
+		{"$..q.*", []interface{}{5.0, 6.0}},
+	}
+	for idx, tcase := range tcases {
+		res, err := JsonPathLookUp(j, tcase.Path)
+		if err != nil || !reflect.DeepEqual(res, tcase.Expect) {
+			t.Errorf("idx: %d, path: %s, (got)%v != (exp)%v, err: %v", idx, tcase.Path, res, tcase.Expect, err)
+		}
+	}
+
+	json.Unmarshal([]byte(`{"user": {"phone": "13800138000", "mobile": "13900139000", "age": 18}, "list": [{"x": 1}, {"x": 2}]}`), &j)
+	_, err := JsonPathLookUpAndDesensitization(j, "$.user.*", PhoneDesensitization)
+	if err != nil {
+		t.Fatalf("failed to desensitize: %v", err)
+	}
+	user := j.(map[string]interface{})["user"].(map[string]interface{})
+	if user["phone"] != "138****8000" || user["mobile"] != "139****9000" || user["age"] != 18.0 {
+		t.Errorf("desensitization failed: %v", user)
+	}
+	_, err = JsonPathLookUpAndDel(j, "$.list[*].*")
+	if err != nil || fmt.Sprintf("%v", j.(map[string]interface{})["list"]) != "[map[] map[]]" {
+		t.Errorf("children of list elements should be deleted, got: %v, %v", j.(map[string]interface{})["list"], err)
+	}
+	_, err = JsonPathLookUpAndDel(j, "$.user.*")
+	if err != nil || len(j.(map[string]interface{})["user"].(map[string]interface{})) != 0 {
+		t.Errorf("all members of user should be deleted, got: %v, %v", j.(map[string]interface{})["user"], err)
+	}
+	_, err = JsonPathLookUpAndDel(j, "$.list[*]")
+	if err != nil || fmt.Sprintf("%v", j.(map[string]interface{})["list"]) != "[]" {
+		t.Errorf("all list elements should be deleted, got: %v, %v", j.(map[string]interface{})["list"], err)
+	}
+}
+
+//根节点是数组和数组嵌套数组时，通配符、下标和范围可以出现在任意位置
+//和Lookup一样，多个结果组成的列表后面的下标从列表中选择，例如 $.a.*[0] 选中第一个成员
+func Test_jsonpath_operate_arrays(t *testing.T) {
+	tcases := []struct {
+		Doc  string
+		Path string
+		Del  string
+		Mask string
+	}{
+		{`["13800138000", "13900139000"]`, "$[*]", `[]`, `["138****8000", "139****9000"]`},
+		{`["13800138000", "13900139000"]`, "$[0]", `["13900139000"]`, `["138****8000", "13900139000"]`},
+		{`["13800138000", "13900139000"]`, "$[0:1]", `[]`, `["138****8000", "139****9000"]`},
+		{`["13800138000", "13900139000"]`, "$[-1:]", `["13800138000"]`, `["13800138000", "139****9000"]`},
+		{`{"a": [["13800138000", "13900139000"], ["13700137000"]]}`, "$.a[*][*]", `{"a": [[], []]}`, `{"a": [["138****8000", "139****9000"], ["137****7000"]]}`},
+		{`{"a": [["13800138000", "13900139000"], "13700137000"]}`, "$.a.*[0]", `{"a": ["13700137000"]}`, `{"a": [["13800138000", "13900139000"], "13700137000"]}`},
+		{`{"a": [["13800138000", "13900139000"], "13700137000"]}`, "$.a.*[1]", `{"a": [["13800138000", "13900139000"]]}`, `{"a": [["13800138000", "13900139000"], "137****7000"]}`},
+		{`{"b": [["13800138000", "13900139000"], ["13700137000"]]}`, "$.b[0][0]", `{"b": [["13900139000"], ["13700137000"]]}`, `{"b": [["138****8000", "13900139000"], ["13700137000"]]}`},
+		{`{"b": [["13800138000", "13900139000"], ["13700137000"]]}`, "$.b[1][*]", `{"b": [["13800138000", "13900139000"], []]}`, `{"b": [["13800138000", "13900139000"], ["137****7000"]]}`},
+		{`[["13800138000"], {"p": "13900139000"}]`, "$[0][0]", `[[], {"p": "13900139000"}]`, `[["138****8000"], {"p": "13900139000"}]`},
+		{`[["13800138000"], {"p": "13900139000"}]`, "$[1].*", `[["13800138000"], {}]`, `[["13800138000"], {"p": "139****9000"}]`},
+	}
+	for idx, tcase := range tcases {
+		var j, expect interface{}
+		json.Unmarshal([]byte(tcase.Doc), &j)
+		json.Unmarshal([]byte(tcase.Del), &expect)
+		res, err := JsonPathLookUpAndDel(j, tcase.Path)
+		if err != nil || !reflect.DeepEqual(res, expect) {
+			t.Errorf("idx: %d, del: %s, (got)%v != (exp)%v, err: %v", idx, tcase.Path, toString(res), tcase.Del, err)
+		}
+		json.Unmarshal([]byte(tcase.Doc), &j)
+		json.Unmarshal([]byte(tcase.Mask), &expect)
+		res, err = JsonPathLookUpAndDesensitization(j, tcase.Path, PhoneDesensitization)
+		if err != nil || !reflect.DeepEqual(res, expect) {
+			t.Errorf("idx: %d, mask: %s, (got)%v != (exp)%v, err: %v", idx, tcase.Path, toString(res), tcase.Mask, err)
+		}
+	}
+}
+
+func Test_jsonpath_descendant(t *testing.T) {
+	var j interface{}
+	json.Unmarshal([]byte(`{"a": {"a": 1, "b": [{"price": 5}, {"price": 15, "b": 2}]}, "c": [[3, 4], [5]]}`), &j)
+	tcases := []struct {
+		Path   string
+		Expect string
+	}{
+		{"$..a", `[{"a":1,"b":[{"price":5},{"b":2,"price":15}]},1]`},
+		{"$.c..*", `[[3,4],[5],3,4,5]`},
+		{"$.c..[0]", `[[3,4],3,5]`},
+		{"$.c..[-1]", `[[5],4,5]`},
+		{"$..[?(@.price == 15)]", `[{"b":2,"price":15}]`},
+		{"$..['a','b']", `[{"a":1,"b":[{"price":5},{"b":2,"price":15}]},1,[{"price":5},{"b":2,"price":15}],2]`},
+		{"$.a..[1:]", `[{"b":2,"price":15}]`},
+		{"$.a..[0].price", `[5]`},
+	}
+	for idx, tcase := range tcases {
+		var expect interface{}
+		json.Unmarshal([]byte(tcase.Expect), &expect)
+		res, err := JsonPathLookUp(j, tcase.Path)
+		if err != nil || !reflect.DeepEqual(res, expect) {
+			t.Errorf("idx: %d, path: %s, (got)%v != (exp)%v, err: %v", idx, tcase.Path, res, expect, err)
+		}
+	}
+
+	json.Unmarshal([]byte(`{"user": {"phone": "13800138000", "contact": {"phone": "13900139000", "tel": 1}}, "list": [{"id": 1, "tags": ["x", "y"]}, {"id": 2}]}`), &j)
+	_, err := JsonPathLookUpAndDesensitization(j, "$..['phone','tel']", PhoneDesensitization)
+	if err != nil {
+		t.Fatalf("failed to desensitize: %v", err)
+	}
+	res, _ := JsonPathLookUp(j, "$..phone")
+	if !reflect.DeepEqual(res, []interface{}{"138****8000", "139****9000"}) {
+		t.Errorf("nested phone should be desensitized, got: %v", res)
+	}
+	_, err = JsonPathLookUpAndDel(j, "$..[0]")
+	if err != nil {
+		t.Fatalf("failed to delete: %v", err)
+	}
+	res, _ = JsonPathLookUp(j, "$.list")
+	if fmt.Sprintf("%v", res) != "[map[id:2]]" {
+		t.Errorf("first element of every array should be deleted, got: %v", res)
+	}
+	_, err = JsonPathLookUpAndDel(j, "$..*")
+	if err != nil || len(j.(map[string]interface{})) != 0 {
+		t.Errorf("all members should be deleted, got: %v, %v", j, err)
+	}
+}
+
+//'..'按文档顺序返回匹配项，对象的成员按键值排序，多次执行的结果相同
+func Test_jsonpath_descendant_order(t *testing.T) {
+	doc := `{"z": {"name": "z1", "b": {"name": "z2"}}, "a": [{"name": "a1"}, {"name": "a2"}], "name": "root", "m": {"name": "m1"}}`
+	tcases := []struct {
+		Path   string
+		Expect string
+	}{
+		{"$..name", `["root","a1","a2","m1","z1","z2"]`},
+		{"$..name[0]", `["root"]`},
+		{"$..name[1:3]", `["a1","a2","m1"]`},
+		{"$..name[-1]", `["z2"]`},
+		{"$..name[::2]", `["root","a2","z1"]`},
+	}
+	for idx, tcase := range tcases {
+		var expect interface{}
+		json.Unmarshal([]byte(tcase.Expect), &expect)
+		for i := 0; i < 20; i++ {
+			var j interface{}
+			json.Unmarshal([]byte(doc), &j)
+			res, err := JsonPathLookUp(j, tcase.Path)
+			if err != nil || !reflect.DeepEqual(res, expect) {
+				t.Errorf("idx: %d, path: %s, (got)%v != (exp)%v, err: %v", idx, tcase.Path, res, expect, err)
+				break
+			}
+		}
+	}
+
+	for i := 0; i < 20; i++ {
+		var j interface{}
+		json.Unmarshal([]byte(doc), &j)
+		if _, err := JsonPathLookUpAndDel(j, "$..name[1:3]"); err != nil {
+			t.Fatal(err)
+		}
+		res, _ := JsonPathLookUp(j, "$..name")
+		if !reflect.DeepEqual(res, []interface{}{"root", "z1", "z2"}) {
+			t.Errorf("$..name[1:3] should delete a1, a2 and m1, got: %v", res)
+			break
+		}
+		if _, err := JsonPathLookUpAndDesensitization(j, "$..name[-1]", NameDesensitization); err != nil {
+			t.Fatal(err)
+		}
+		res, _ = JsonPathLookUp(j, "$..name")
+		names := res.([]interface{})
+		if !reflect.DeepEqual(names[:2], []interface{}{"root", "z1"}) || names[2] == "z2" {
+			t.Errorf("$..name[-1] should only desensitize z2, got: %v", res)
+			break
+		}
+	}
+}
+
+//多个goroutine同时用同一个Compiled修改各自的数据，结果应该和单独执行时一致
+//使用 go test -race 运行时同时检查数据竞争
+func Test_jsonpath_operate_concurrently(t *testing.T) {
+	doc := `{"user": {"phone": "13800138000", "id": 1, "contact": {"phone": "13900139000", "id": 2}}, "list": [{"phone": "13700137000", "id": 3}]}`
+	tcases := []struct {
+		Path   string
+		Mode   string
+		Func   string
+		Expect string
+	}{
+		{"$..contact[0]", DataFieldControl, "", `{"user":{"phone":"13800138000","id":1},"list":[{"phone":"13700137000","id":3}]}`},
+		{"$..contact[-1]", DataFieldControl, "", `{"user":{"phone":"13800138000","id":1},"list":[{"phone":"13700137000","id":3}]}`},
+		{"$..contact[1:]", DataFieldControl, "", doc},
+		{"$..id", DataFieldControl, "", `{"user":{"phone":"13800138000","contact":{"phone":"13900139000"}},"list":[{"phone":"13700137000"}]}`},
+		{"$..phone", DataDesensitizationControl, PhoneDesensitization, `{"user":{"phone":"138****8000","id":1,"contact":{"phone":"139****9000","id":2}},"list":[{"phone":"137****7000","id":3}]}`},
+		{"$.list[?(@.id == 3)]", DataFieldControl, "", `{"user":{"phone":"13800138000","id":1,"contact":{"phone":"13900139000","id":2}},"list":[]}`},
+		{"$.user.*", DataDesensitizationControl, PhoneDesensitization, `{"user":{"phone":"138****8000","id":1,"contact":{"phone":"13900139000","id":2}},"list":[{"phone":"13700137000","id":3}]}`},
+	}
+	compiled := make([]*Compiled, len(tcases))
+	for idx, tcase := range tcases {
+		compiled[idx] = MustCompile(tcase.Path)
+	}
+	var wg sync.WaitGroup
+	for g := 0; g < 16; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 200; i++ {
+				idx := (g + i) % len(tcases)
+				tcase := tcases[idx]
+				var obj, expect interface{}
+				json.Unmarshal([]byte(doc), &obj)
+				json.Unmarshal([]byte(tcase.Expect), &expect)
+				_, err := compiled[idx].LookupAndOperate(obj, tcase.Mode, tcase.Func)
+				if err != nil {
+					t.Errorf("idx: %d, path: %s, error: %v", idx, tcase.Path, err)
+					return
+				}
+				if !reflect.DeepEqual(obj, expect) {
+					t.Errorf("idx: %d, path: %s, (got)%v != (exp)%v", idx, tcase.Path, obj, expect)
+					return
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+}
@@ -0,0 +1,345 @@
+package jsonpath
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+//词法单元类型
+type tokenKind int
+
+const (
+	tokEOF      tokenKind = iota
+	tokRoot               // $
+	tokCurrent            // @
+	tokDot                // .
+	tokDotDot             // ..
+	tokLBracket           // [
+	tokRBracket           // ]
+	tokLParen             // (
+	tokRParen             // )
+	tokLBrace             // {
+	tokRBrace             // }
+	tokStar               // *
+	tokComma              // ,
+	tokColon              // :
+	tokQuestion           // ?
+	tokName               // 未加引号的名字
+	tokString             // 加引号的字符串
+	tokNumber             // 数字
+	tokRegexp             // /pattern/
+	tokOperator           // == != < <= > >= =~ ! && ||
+)
+
+var tokenKindNames = map[tokenKind]string{
+	tokEOF:      "end of path",
+	tokRoot:     "'$'",
+	tokCurrent:  "'@'",
+	tokDot:      "'.'",
+	tokDotDot:   "'..'",
+	tokLBracket: "'['",
+	tokRBracket: "']'",
+	tokLParen:   "'('",
+	tokRParen:   "')'",
+	tokLBrace:   "'{'",
+	tokRBrace:   "'}'",
+	tokStar:     "'*'",
+	tokComma:    "','",
+	tokColon:    "':'",
+	tokQuestion: "'?'",
+	tokName:     "name",
+	tokString:   "string",
+	tokNumber:   "number",
+	tokRegexp:   "regexp",
+	tokOperator: "operator",
+}
+
+func (k tokenKind) String() string {
+	return tokenKindNames[k]
+}
+
+//单个词法单元
+//kind 词法单元类型
+//val 词法单元的值，字符串类型为去掉引号后的内容
+//pos 在jsonpath中的起始字节偏移
+//end 在jsonpath中的结束字节偏移(不包含)
+type lexToken struct {
+	kind tokenKind
+	val  string
+	pos  int
+	end  int
+}
+
+//空白字符
+const blankChars = " \t\r\n"
+
+//在'.'之后出现时会终止名字的字符
+const nameStopChars = " \t\r\n.[](){}'\",=<>!&|?:*/"
+
+//将jsonpath切分为带位置的词法单元，最后一个单元总是tokEOF
+//rfc 为true时字符串按照RFC 9535的规则严格检查
+func lex(input string, rfc bool) ([]lexToken, error) {
+	tokens := []lexToken{}
+	pos := 0
+	for pos < len(input) {
+		c := input[pos]
+		if strings.IndexByte(blankChars, c) >= 0 {
+			pos++
+			continue
+		}
+		start := pos
+		//'.'之后的名字可以以数字开头，也可以包含'-'等字符
+		afterDot := len(tokens) > 0 && (tokens[len(tokens)-1].kind == tokDot || tokens[len(tokens)-1].kind == tokDotDot) &&
+			tokens[len(tokens)-1].end == pos
+		switch {
+		case afterDot && !strings.ContainsRune(nameStopChars, rune(c)):
+			pos = scanName(input, pos, true)
+			tokens = append(tokens, lexToken{tokName, input[start:pos], start, pos})
+			continue
+		case c == '.':
+			if pos+1 < len(input) && input[pos+1] == '.' {
+				tokens = append(tokens, lexToken{tokDotDot, "..", start, pos + 2})
+				pos += 2
+			} else {
+				tokens = append(tokens, lexToken{tokDot, ".", start, pos + 1})
+				pos++
+			}
+			continue
+		case c == '\'' || c == '"':
+			val, end, err := scanString(input, pos, rfc)
+			if err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, lexToken{tokString, val, start, end})
+			pos = end
+			continue
+		case c == '/':
+			end, err := scanRegexp(input, pos)
+			if err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, lexToken{tokRegexp, input[start:end], start, end})
+			pos = end
+			continue
+		case c == '-' || (c >= '0' && c <= '9'):
+			end := scanNumber(input, pos)
+			if end == pos {
+				return nil, newSyntaxError(input, pos, "-", "invalid character '-'", "number")
+			}
+			tokens = append(tokens, lexToken{tokNumber, input[start:end], start, end})
+			pos = end
+			continue
+		}
+		if kind, ok := singleCharTokens[c]; ok {
+			tokens = append(tokens, lexToken{kind, string(c), start, pos + 1})
+			pos++
+			continue
+		}
+		if op := scanOperator(input, pos); op != "" {
+			tokens = append(tokens, lexToken{tokOperator, op, start, pos + len(op)})
+			pos += len(op)
+			continue
+		}
+		if end := scanName(input, pos, false); end > pos {
+			tokens = append(tokens, lexToken{tokName, input[start:end], start, end})
+			pos = end
+			continue
+		}
+		r, _ := utf8.DecodeRuneInString(input[pos:])
+		return nil, newSyntaxError(input, pos, string(r), fmt.Sprintf("invalid character %q", r))
+	}
+	tokens = append(tokens, lexToken{tokEOF, "", len(input), len(input)})
+	return tokens, nil
+}
+
+var singleCharTokens = map[byte]tokenKind{
+	'$': tokRoot,
+	'@': tokCurrent,
+	'[': tokLBracket,
+	']': tokRBracket,
+	'(': tokLParen,
+	')': tokRParen,
+	'{': tokLBrace,
+	'}': tokRBrace,
+	'*': tokStar,
+	',': tokComma,
+	':': tokColon,
+	'?': tokQuestion,
+}
+
+//过滤表达式中支持的操作符，长的在前面
+var operators = []string{"==", "!=", "<=", ">=", "=~", "&&", "||", "<", ">", "!"}
+
+func scanOperator(input string, pos int) string {
+	for _, op := range operators {
+		if strings.HasPrefix(input[pos:], op) {
+			return op
+		}
+	}
+	return ""
+}
+
+//扫描名字，返回名字结束的位置
+//afterDot 为true时除了nameStopChars中的字符都可以出现在名字中
+func scanName(input string, pos int, afterDot bool) int {
+	for pos < len(input) {
+		r, size := utf8.DecodeRuneInString(input[pos:])
+		if afterDot {
+			if strings.ContainsRune(nameStopChars, r) {
+				break
+			}
+		} else if !isNameRune(r) {
+			break
+		}
+		pos += size
+	}
+	return pos
+}
+
+func isNameRune(r rune) bool {
+	return r == '_' || r == '-' || r >= utf8.RuneSelf ||
+		(r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9')
+}
+
+//扫描数字，支持负数、小数和指数
+func scanNumber(input string, pos int) int {
+	start := pos
+	if pos < len(input) && input[pos] == '-' {
+		pos++
+	}
+	digits := pos
+	for pos < len(input) && input[pos] >= '0' && input[pos] <= '9' {
+		pos++
+	}
+	if pos == digits {
+		return start
+	}
+	if pos+1 < len(input) && input[pos] == '.' && input[pos+1] >= '0' && input[pos+1] <= '9' {
+		pos++
+		for pos < len(input) && input[pos] >= '0' && input[pos] <= '9' {
+			pos++
+		}
+	}
+	if pos < len(input) && (input[pos] == 'e' || input[pos] == 'E') {
+		exp := pos + 1
+		if exp < len(input) && (input[exp] == '+' || input[exp] == '-') {
+			exp++
+		}
+		if exp < len(input) && input[exp] >= '0' && input[exp] <= '9' {
+			pos = exp
+			for pos < len(input) && input[pos] >= '0' && input[pos] <= '9' {
+				pos++
+			}
+		}
+	}
+	return pos
+}
+
+//扫描单引号或双引号字符串，返回去掉引号并处理转义后的值和结束位置
+//支持的转义: \b \f \n \r \t \/ \\ \' \" \uXXXX
+//rfc 为true时不允许未转义的控制字符，且只能转义当前使用的引号
+func scanString(input string, pos int, rfc bool) (string, int, error) {
+	quote := input[pos]
+	var sb strings.Builder
+	i := pos + 1
+	for i < len(input) {
+		c := input[i]
+		switch {
+		case c == quote:
+			return sb.String(), i + 1, nil
+		case c == '\\':
+			if i+1 >= len(input) {
+				return "", 0, newSyntaxError(input, pos, input[pos:], "unterminated string", "closing quote")
+			}
+			if rfc && (input[i+1] == '\'' || input[i+1] == '"') && input[i+1] != quote {
+				return "", 0, newSyntaxError(input, i, input[i:i+2], "invalid escape sequence")
+			}
+			if simple, ok := simpleEscapes[input[i+1]]; ok {
+				sb.WriteByte(simple)
+				i += 2
+				continue
+			}
+			if input[i+1] != 'u' {
+				return "", 0, newSyntaxError(input, i, input[i:i+2], "invalid escape sequence")
+			}
+			r, size, err := scanUnicodeEscape(input, i)
+			if err != nil {
+				return "", 0, err
+			}
+			sb.WriteRune(r)
+			i += size
+		case rfc && c < 0x20:
+			return "", 0, newSyntaxError(input, i, input[i:i+1], "control character must be escaped in string")
+		default:
+			sb.WriteByte(c)
+			i++
+		}
+	}
+	return "", 0, newSyntaxError(input, pos, input[pos:], "unterminated string", "closing quote")
+}
+
+var simpleEscapes = map[byte]byte{
+	'b':  '\b',
+	'f':  '\f',
+	'n':  '\n',
+	'r':  '\r',
+	't':  '\t',
+	'/':  '/',
+	'\\': '\\',
+	'\'': '\'',
+	'"':  '"',
+}
+
+//解析 \uXXXX 转义，支持 \uD83D\uDE00 形式的代理对，返回字符和转义占用的字节数
+func scanUnicodeEscape(input string, pos int) (rune, int, error) {
+	hex := func(at int) (rune, bool) {
+		if at+6 > len(input) || input[at] != '\\' || input[at+1] != 'u' {
+			return 0, false
+		}
+		v, err := strconv.ParseUint(input[at+2:at+6], 16, 32)
+		return rune(v), err == nil
+	}
+	r, ok := hex(pos)
+	if !ok {
+		end := pos + 6
+		if end > len(input) {
+			end = len(input)
+		}
+		return 0, 0, newSyntaxError(input, pos, input[pos:end], "invalid unicode escape", "\\uXXXX")
+	}
+	if utf16.IsSurrogate(r) {
+		r2, ok := hex(pos + 6)
+		if !ok || r >= 0xDC00 {
+			return 0, 0, newSyntaxError(input, pos, input[pos:pos+6], "invalid unicode surrogate pair")
+		}
+		r = utf16.DecodeRune(r, r2)
+		if r == utf8.RuneError {
+			return 0, 0, newSyntaxError(input, pos, input[pos:pos+12], "invalid unicode surrogate pair")
+		}
+		return r, 12, nil
+	}
+	return r, 6, nil
+}
+
+//扫描 /pattern/ 形式的正则表达式，'/'后紧跟的字母作为标志一起返回
+func scanRegexp(input string, pos int) (int, error) {
+	i := pos + 1
+	for i < len(input) {
+		switch input[i] {
+		case '\\':
+			i += 2
+			continue
+		case '/':
+			i++
+			for i < len(input) && ((input[i] >= 'a' && input[i] <= 'z') || (input[i] >= 'A' && input[i] <= 'Z')) {
+				i++
+			}
+			return i, nil
+		}
+		i++
+	}
+	return 0, newSyntaxError(input, pos, input[pos:], "unterminated regexp", "'/'")
+}
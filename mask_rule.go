@@ -0,0 +1,231 @@
+package jsonpath
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"unicode/utf8"
+
+	"gopkg.in/yaml.v3"
+)
+
+//声明式的脱敏规则
+//MinLength/MaxLength 规则适用的长度范围(按字符计算)，为0时不限制
+//Skip 为true时保持原值
+//Pattern 不为空时只脱敏正则表达式匹配到的部分，表达式中有分组时只脱敏各个分组
+//Replacement 不为空时需要脱敏的部分整体替换为Replacement
+//Size 大于0时脱敏从Start开始的Size个字符，否则保留开头KeepFirst个和末尾KeepLast个字符，脱敏中间部分
+//FixedLength 大于0时脱敏的部分固定替换为FixedLength个MaskChar，不暴露原来的长度
+//MaskChar 脱敏使用的字符，可以是多字节字符，默认为*
+type MaskRule struct {
+	MinLength   int    `json:"min_length"`
+	MaxLength   int    `json:"max_length"`
+	Skip        bool   `json:"skip"`
+	Pattern     string `json:"pattern"`
+	Replacement string `json:"replacement"`
+	Start       int    `json:"start"`
+	Size        int    `json:"size"`
+	KeepFirst   int    `json:"keep_first"`
+	KeepLast    int    `json:"keep_last"`
+	FixedLength int    `json:"fixed_length"`
+	MaskChar    string `json:"mask_char"`
+
+	re *regexp.Regexp
+}
+
+//脱敏策略，按顺序使用第一个长度适用的规则
+//没有适用的规则时返回内容为Error的错误，Error为空时保持原值
+type MaskStrategy struct {
+	Name  string     `json:"name"`
+	Rules []MaskRule `json:"rules"`
+	Error string     `json:"error"`
+}
+
+//检查规则是否合法并编译正则表达式
+func (r *MaskRule) compile() error {
+	if r.MinLength < 0 || r.MaxLength < 0 || r.Start < 0 || r.Size < 0 || r.KeepFirst < 0 || r.KeepLast < 0 || r.FixedLength < 0 {
+		return fmt.Errorf("mask rule error: length and position should not be negative")
+	}
+	if r.MaxLength > 0 && r.MinLength > r.MaxLength {
+		return fmt.Errorf("mask rule error: min_length %d is over max_length %d", r.MinLength, r.MaxLength)
+	}
+	if utf8.RuneCountInString(r.MaskChar) > 1 {
+		return fmt.Errorf("mask rule error: mask_char should be a single character: %s", r.MaskChar)
+	}
+	if r.Pattern != "" {
+		re, err := regexp.Compile(r.Pattern)
+		if err != nil {
+			return fmt.Errorf("mask rule error: %v", err)
+		}
+		r.re = re
+	}
+	return nil
+}
+
+//规则是否适用于长度为length的值
+func (r *MaskRule) match(length int) bool {
+	if r.MinLength > 0 && length < r.MinLength {
+		return false
+	}
+	if r.MaxLength > 0 && length > r.MaxLength {
+		return false
+	}
+	return true
+}
+
+//按规则脱敏value
+func (r *MaskRule) Mask(value string) (string, error) {
+	if r.Skip {
+		return value, nil
+	}
+	if r.Pattern == "" {
+		res, err := r.mask_segment([]rune(value))
+		return string(res), err
+	}
+	re := r.re
+	if re == nil {
+		var err error
+		if re, err = regexp.Compile(r.Pattern); err != nil {
+			return "", fmt.Errorf("mask rule error: %v", err)
+		}
+	}
+	res := []rune{}
+	last := 0
+	for _, loc := range re.FindAllStringSubmatchIndex(value, -1) {
+		//没有分组时脱敏整个匹配项
+		segments := loc[2:]
+		if len(segments) == 0 {
+			segments = loc[:2]
+		}
+		for i := 0; i < len(segments); i += 2 {
+			//没有匹配到的分组和嵌套在已处理分组中的分组跳过
+			if segments[i] < last {
+				continue
+			}
+			masked, err := r.mask_segment([]rune(value[segments[i]:segments[i+1]]))
+			if err != nil {
+				return "", err
+			}
+			res = append(res, []rune(value[last:segments[i]])...)
+			res = append(res, masked...)
+			last = segments[i+1]
+		}
+	}
+	res = append(res, []rune(value[last:])...)
+	return string(res), nil
+}
+
+//脱敏一段字符
+func (r *MaskRule) mask_segment(value []rune) ([]rune, error) {
+	if r.Replacement != "" {
+		return []rune(r.Replacement), nil
+	}
+	start, end := r.KeepFirst, len(value)-r.KeepLast
+	if r.Size > 0 {
+		if r.Start > len(value) {
+			return nil, fmt.Errorf("start pos error: %d is over the value length", r.Start)
+		}
+		start, end = r.Start, r.Start+r.Size
+	}
+	if end > len(value) {
+		end = len(value)
+	}
+	if start >= end {
+		return value, nil
+	}
+	maskChar := '*'
+	if r.MaskChar != "" {
+		maskChar, _ = utf8.DecodeRuneInString(r.MaskChar)
+	}
+	size := end - start
+	if r.FixedLength > 0 {
+		size = r.FixedLength
+	}
+	res := make([]rune, 0, start+size+len(value)-end)
+	res = append(res, value[:start]...)
+	for i := 0; i < size; i++ {
+		res = append(res, maskChar)
+	}
+	return append(res, value[end:]...), nil
+}
+
+//检查策略中的所有规则
+func (s *MaskStrategy) compile() error {
+	for i := range s.Rules {
+		if err := s.Rules[i].compile(); err != nil {
+			return fmt.Errorf("%s rule %d: %v", s.Name, i, err)
+		}
+	}
+	return nil
+}
+
+//按策略脱敏value
+func (s *MaskStrategy) Mask(value string) (string, error) {
+	length := utf8.RuneCountInString(value)
+	for i := range s.Rules {
+		if s.Rules[i].match(length) {
+			return s.Rules[i].Mask(value)
+		}
+	}
+	if s.Error != "" {
+		return "", errors.New(s.Error)
+	}
+	return value, nil
+}
+
+//作为DesensitizationFuncs中的脱敏函数使用
+func (s *MaskStrategy) handle(jsonMap map[string]interface{}, key string) error {
+	value, err := mask_value(jsonMap, key)
+	if err != nil {
+		return err
+	}
+	res, err := s.Mask(value)
+	if err != nil {
+		return err
+	}
+	jsonMap[key] = res
+	return nil
+}
+
+//把策略注册到DesensitizationFuncs中，之后可以通过策略名脱敏
+//同名的脱敏函数会被替换，DesensitizationFuncs不是并发安全的，需要在使用前注册
+func RegisterMaskStrategy(s *MaskStrategy) error {
+	if s.Name == "" {
+		return fmt.Errorf("mask strategy name is empty")
+	}
+	if err := s.compile(); err != nil {
+		return err
+	}
+	DesensitizationFuncs[s.Name] = s.handle
+	return nil
+}
+
+//从json中读取脱敏策略，格式为策略组成的数组
+// [{"name": "bank_card", "rules": [{"keep_first": 6, "keep_last": 4}]}]
+func LoadMaskStrategies(data []byte) ([]*MaskStrategy, error) {
+	var strategies []*MaskStrategy
+	if err := json.Unmarshal(data, &strategies); err != nil {
+		return nil, err
+	}
+	for _, s := range strategies {
+		if err := s.compile(); err != nil {
+			return nil, err
+		}
+	}
+	return strategies, nil
+}
+
+//从yaml中读取脱敏策略，格式与LoadMaskStrategies相同
+//使用gopkg.in/yaml.v3解析，支持block和flow风格，例如 - {keep_first: 6, keep_last: 4}
+func LoadMaskStrategiesYAML(data []byte) ([]*MaskStrategy, error) {
+	var obj interface{}
+	if err := yaml.Unmarshal(data, &obj); err != nil {
+		return nil, err
+	}
+	jsonData, err := json.Marshal(obj)
+	if err != nil {
+		return nil, err
+	}
+	return LoadMaskStrategies(jsonData)
+}
@@ -0,0 +1,180 @@
+package jsonpath
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func Test_jsonpath_mask_rule(t *testing.T) {
+	tcases := []struct {
+		Rule   MaskRule
+		Value  string
+		Expect string
+	}{
+		{MaskRule{Start: 3, Size: 4}, "13800138000", "138****8000"},
+		{MaskRule{Start: 1, Size: 10}, "abc", "a**"},
+		{MaskRule{KeepFirst: 6, KeepLast: 4}, "6222021234567890123", "622202*********0123"},
+		{MaskRule{KeepFirst: 2, KeepLast: 2}, "abc", "abc"},
+		{MaskRule{KeepLast: 4, FixedLength: 4}, "6222021234567890123", "****0123"},
+		{MaskRule{KeepFirst: 1, MaskChar: "●"}, "张三丰", "张●●"},
+		{MaskRule{Replacement: "[REDACTED]"}, "secret", "[REDACTED]"},
+		{MaskRule{Skip: true, KeepFirst: 1}, "secret", "secret"},
+		{MaskRule{Pattern: `^.([^@]*)@`}, "alice@example.com", "a****@example.com"},
+		{MaskRule{Pattern: `\d{4}`, KeepLast: 1}, "id 12345678 no 9", "id ***4***8 no 9"},
+		{MaskRule{Pattern: `(\d+)-(\d+)`, FixedLength: 3}, "tel 010-1234", "tel ***-***"},
+		{MaskRule{Pattern: `x`}, "abc", "abc"},
+	}
+	for idx, tcase := range tcases {
+		if err := tcase.Rule.compile(); err != nil {
+			t.Errorf("idx: %d, compile error: %v", idx, err)
+			continue
+		}
+		res, err := tcase.Rule.Mask(tcase.Value)
+		if err != nil || res != tcase.Expect {
+			t.Errorf("idx: %d, value: %s, (got)%v != (exp)%v, err: %v", idx, tcase.Value, res, tcase.Expect, err)
+		}
+	}
+
+	for idx, rule := range []MaskRule{
+		{KeepFirst: -1},
+		{MinLength: 5, MaxLength: 3},
+		{MaskChar: "**"},
+		{Pattern: "("},
+	} {
+		if err := rule.compile(); err == nil {
+			t.Errorf("idx: %d, rule: %v, error not raised", idx, rule)
+		}
+	}
+}
+
+func Test_jsonpath_mask_strategy(t *testing.T) {
+	tcases := []struct {
+		Strategy *MaskStrategy
+		Value    string
+		Expect   string
+		Err      bool
+	}{
+		{phoneStrategy, "13800138000", "138****8000", false},
+		{phoneStrategy, "010-8888888", "010****8888", false},
+		{phoneStrategy, "010-88888888", "010-****8888", false},
+		{phoneStrategy, "8888888", "8****88", false},
+		{phoneStrategy, "110", "110", false},
+		{phoneStrategy, "138001380001234", "", true},
+		{idCardNumberStrategy, "110101199003071234", "110101********1234", false},
+		{idCardNumberStrategy, "A1234567", "A****567", false},
+		{nameStrategy, "张三", "张*", false},
+		{nameStrategy, "张三丰", "张*丰", false},
+		{nameStrategy, "欧阳娜娜", "欧**娜", false},
+		{&MaskStrategy{Rules: []MaskRule{{MinLength: 3, KeepFirst: 1}}}, "ab", "ab", false},
+	}
+	for idx, tcase := range tcases {
+		res, err := tcase.Strategy.Mask(tcase.Value)
+		if (err != nil) != tcase.Err || res != tcase.Expect {
+			t.Errorf("idx: %d, value: %s, (got)%v != (exp)%v, err: %v", idx, tcase.Value, res, tcase.Expect, err)
+		}
+	}
+}
+
+func Test_jsonpath_load_mask_strategies(t *testing.T) {
+	jsonData := `[
+		{"name": "test_bank_card", "rules": [{"min_length": 12, "keep_first": 6, "keep_last": 4}, {"replacement": "****"}]},
+		{"name": "test_email", "error": "email error", "rules": [{"pattern": "^.([^@]*)@", "fixed_length": 3}]}
+	]`
+	yamlData := `
+# 银行卡号保留前6位和后4位
+- name: test_bank_card
+  rules:
+    - min_length: 12
+      keep_first: 6
+      keep_last: 4
+    - replacement: "****"
+- name: 'test_email'
+  error: email error
+  rules:
+  - pattern: '^.([^@]*)@'  # 只脱敏@前的部分
+    fixed_length: 3
+`
+	fromJson, err := LoadMaskStrategies([]byte(jsonData))
+	if err != nil {
+		t.Fatal(err)
+	}
+	fromYaml, err := LoadMaskStrategiesYAML([]byte(yamlData))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(fromJson, fromYaml) {
+		t.Fatalf("(got)%v != (exp)%v", toString(fromYaml), toString(fromJson))
+	}
+	for _, s := range fromYaml {
+		if err := RegisterMaskStrategy(s); err != nil {
+			t.Fatal(err)
+		}
+	}
+	defer delete(DesensitizationFuncs, "test_bank_card")
+	defer delete(DesensitizationFuncs, "test_email")
+
+	var j interface{}
+	json.Unmarshal([]byte(`{"card": "6222021234567890123", "short": "12345", "email": "alice@example.com"}`), &j)
+	for _, tcase := range []struct{ Path, Func string }{
+		{"$.card", "test_bank_card"},
+		{"$.short", "test_bank_card"},
+		{"$.email", "test_email"},
+	} {
+		if _, err := JsonPathLookUpAndDesensitization(j, tcase.Path, tcase.Func); err != nil {
+			t.Fatal(err)
+		}
+	}
+	expect := map[string]interface{}{"card": "622202*********0123", "short": "****", "email": "a***@example.com"}
+	if !reflect.DeepEqual(j, expect) {
+		t.Errorf("(got)%v != (exp)%v", j, expect)
+	}
+
+	for idx, data := range []string{
+		`[{"name": "x", "rules": [{"pattern": "("}]}]`,
+		`{"name": "x"}`,
+	} {
+		if _, err := LoadMaskStrategies([]byte(data)); err == nil {
+			t.Errorf("idx: %d, data: %s, error not raised", idx, data)
+		}
+	}
+	if err := RegisterMaskStrategy(&MaskStrategy{}); err == nil {
+		t.Errorf("empty strategy name should return error")
+	}
+}
+
+//flow风格的yaml与block风格的结果相同
+func Test_jsonpath_mask_strategy_yaml_flow(t *testing.T) {
+	block := `
+- name: test_flow
+  rules:
+    - keep_first: 1
+    - replacement: "**"
+`
+	expect, err := LoadMaskStrategiesYAML([]byte(block))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for idx, data := range []string{
+		"- name: test_flow\n  rules: [{keep_first: 1}, {replacement: \"**\"}]",
+		"- name: test_flow\n  rules:\n    - {keep_first: 1}\n    - {replacement: \"**\"}",
+		`[{name: test_flow, rules: [{keep_first: 1}, {replacement: "**"}]}]`,
+		`[{"name": "test_flow", "rules": [{"keep_first": 1}, {"replacement": "**"}]}]`,
+	} {
+		res, err := LoadMaskStrategiesYAML([]byte(data))
+		if err != nil || !reflect.DeepEqual(res, expect) {
+			t.Errorf("idx: %d, (got)%v != (exp)%v, err: %v", idx, toString(res), toString(expect), err)
+		}
+	}
+
+	for idx, data := range []string{
+		"- name: x\n  rules: [{keep_first: 1}",
+		"- name: x\n\trules: []",
+		"name: x",
+		"- name: x\n  rules: [{pattern: \"(\"}]",
+	} {
+		if _, err := LoadMaskStrategiesYAML([]byte(data)); err == nil {
+			t.Errorf("idx: %d, data: %q, error not raised", idx, data)
+		}
+	}
+}
@@ -0,0 +1,448 @@
+package jsonpath
+
+import (
+	"fmt"
+	"reflect"
+)
+
+//LookupNodes返回的单个匹配结果
+//Path 匹配节点的规范化路径(normalized path)，例如 $['store']['book'][0]['price']
+//Value 匹配节点的值
+type Node struct {
+	Path  string
+	Value interface{}
+}
+
+//向外暴露通过jsonpath查询节点位置的接口
+func JsonPathLookUpNodes(obj interface{}, jpath string) ([]Node, error) {
+	c, err := Compile(jpath)
+	if err != nil {
+		return nil, err
+	}
+	return c.LookupNodes(obj)
+}
+
+//按编译模式查找所有匹配的节点，RFC 9535模式下不会返回错误
+func (c *Compiled) find_nodes(obj interface{}) ([]*node, error) {
+	if c.query != nil {
+		return evalQuery(c.query, obj, obj), nil
+	}
+	return c.lookup_nodes(obj, false)
+}
+
+//查找所有匹配的节点，返回每个节点的规范化路径和值，结果顺序与Lookup相同
+//Compile模式下选中的节点与LookupAndOperate处理的节点相同，可以在删除或脱敏前记录会被修改的字段
+//Compile模式下Lookup返回错误的情况这里同样返回错误；RFC 9535模式下没有匹配时返回空列表
+func (c *Compiled) LookupNodes(obj interface{}) ([]Node, error) {
+	nodes, err := c.find_nodes(obj)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]Node, len(nodes))
+	for i, n := range nodes {
+		res[i] = Node{Path: n.path(), Value: n.value}
+	}
+	return res, nil
+}
+
+//按Compile模式的语义执行所有步骤，与Lookup的区别是保留每个结果在json中的位置
+//上一步的结果是多个值组成的列表时，和Lookup一样把列表当作数组处理
+//create为true时创建缺少的中间对象和数组，不存在的键值和下标返回值为nil的节点，供Set写入
+//新建的对象和数组在set_node写入下面的值时才写入obj
+func (c *Compiled) lookup_nodes(obj interface{}, create bool) ([]*node, error) {
+	var err error
+	var nodes = []*node{{value: obj}}
+	//当前结果是否是上一步得到的多个节点组成的列表
+	var multi bool
+	for _, s := range c.steps {
+		nodes, multi, err = step_nodes(nodes, multi, obj, s, c.strict, create)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return nodes, nil
+}
+
+//在nodes上执行单个步骤，返回选中的节点和结果是否为列表
+func step_nodes(nodes []*node, multi bool, root interface{}, s step, strict, create bool) ([]*node, bool, error) {
+	var err error
+	//除key和scan之外，步骤中的key表示先取键值再执行后面的操作，例如 $.list[0]
+	if len(s.key) > 0 && s.op != "scan" {
+		nodes, multi, err = get_key_nodes(nodes, multi, s.key, strict, create)
+		if err != nil {
+			return nil, false, err
+		}
+	}
+	switch s.op {
+	case "key":
+	case "idx":
+		nodes, err = get_idx_nodes(nodes, multi, s.args.([]int), create)
+		multi = len(s.args.([]int)) > 1
+	case "range":
+		argsv := s.args.([3]interface{})
+		nodes, err = get_range_nodes(nodes, multi, argsv[0], argsv[1], argsv[2], create)
+		multi = true
+	case "wildcard":
+		nodes, err = get_wildcard_nodes(nodes, multi)
+		multi = true
+	case "union":
+		nodes, err = get_union_nodes(nodes, multi, s.args.([]interface{}))
+		multi = true
+	case "filter":
+		nodes, err = get_filtered_nodes(nodes, multi, root, s.args.(*filterExpr))
+		multi = true
+	case "scan":
+		nodes, err = get_scan_nodes(nodes, multi, root, s)
+		multi = true
+	default:
+		return nil, false, fmt.Errorf("expression don't support in filter")
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return nodes, multi, nil
+}
+
+//单个节点上取子节点，节点是对象时取键值，是数组时对每个元素取键值
+//不存在时忽略，strict为true时返回错误，create为true时返回值为nil的新节点
+func key_children(n *node, key string, strict, create bool) ([]*node, error) {
+	if v, ok := objectGet(n.value, key); ok {
+		return []*node{{value: v, key: key, parent: n}}, nil
+	}
+	if create && n.value == nil && n.parent != nil {
+		n.value = map[string]interface{}{}
+	}
+	if isObject(n.value) && create {
+		return []*node{{key: key, parent: n}}, nil
+	}
+	var res []*node
+	if _, ok := arrayLen(n.value); ok {
+		for _, child := range children(n) {
+			nodes, err := key_children(child, key, strict, create)
+			if err != nil {
+				return nil, err
+			}
+			res = append(res, nodes...)
+		}
+	} else if strict {
+		return nil, fmt.Errorf("key error: %s not found in %s", key, n.path())
+	}
+	return res, nil
+}
+
+//与get_key相同，对象上取键值，数组或列表上对每个元素取键值
+//返回的multi表示结果是否为列表
+func get_key_nodes(nodes []*node, multi bool, key string, strict, create bool) ([]*node, bool, error) {
+	if multi {
+		res := []*node{}
+		for _, n := range nodes {
+			found, err := key_children(n, key, strict, create)
+			if err != nil {
+				return nil, false, err
+			}
+			res = append(res, found...)
+		}
+		return res, true, nil
+	}
+	n := nodes[0]
+	if n.value == nil && create && n.parent != nil {
+		n.value = map[string]interface{}{}
+	}
+	if n.value == nil {
+		return nil, false, ErrGetFromNullObj
+	}
+	if isObject(n.value) {
+		v, ok := objectGet(n.value, key)
+		if !ok && create {
+			return []*node{{key: key, parent: n}}, false, nil
+		}
+		if !ok {
+			return nil, false, fmt.Errorf("key error: %s not found in object", key)
+		}
+		return []*node{{value: v, key: key, parent: n}}, false, nil
+	}
+	if _, ok := arrayLen(n.value); ok {
+		return get_key_nodes(children(n), true, key, strict, create)
+	}
+	return nil, false, fmt.Errorf("object is not map or slice")
+}
+
+//列表中的所有节点或数组节点的所有元素
+//create为true时把值为nil的节点替换成空数组
+func elem_nodes(nodes []*node, multi, create bool) ([]*node, error) {
+	if multi {
+		return nodes, nil
+	}
+	if nodes[0].value == nil && create && nodes[0].parent != nil {
+		nodes[0].value = []interface{}{}
+	}
+	if nodes[0].value == nil {
+		return nil, ErrGetFromNullObj
+	}
+	if _, ok := arrayLen(nodes[0].value); !ok {
+		return nil, fmt.Errorf("object is not Slice")
+	}
+	return children(nodes[0]), nil
+}
+
+//与get_idx相同，负数下标从末尾开始计算，越界时返回错误
+//create为true时数组末尾之后的下标返回值为nil的新节点，写入时数组会被加长
+func get_idx_nodes(nodes []*node, multi bool, idx []int, create bool) ([]*node, error) {
+	if len(idx) == 0 {
+		return nil, fmt.Errorf("cannot index on empty slice")
+	}
+	elems, err := elem_nodes(nodes, multi, create)
+	if err != nil {
+		return nil, err
+	}
+	res := []*node{}
+	for _, x := range idx {
+		i := x
+		if i < 0 {
+			i += len(elems)
+		}
+		if i >= len(elems) && create && !multi {
+			res = append(res, &node{key: i, parent: nodes[0]})
+			continue
+		}
+		if i < 0 || i >= len(elems) {
+			return nil, fmt.Errorf("index out of range: len: %v, idx: %v", len(elems), x)
+		}
+		res = append(res, elems[i])
+	}
+	return res, nil
+}
+
+//与get_range相同，to包含在范围内
+//create为true时range不会创建元素，数组不存在时返回错误
+func get_range_nodes(nodes []*node, multi bool, frm, to, step interface{}, create bool) ([]*node, error) {
+	if create && !multi && nodes[0].value == nil {
+		return nil, fmt.Errorf("range cannot create elements in missing array: %s", nodes[0].path())
+	}
+	elems, err := elem_nodes(nodes, multi, false)
+	if err != nil {
+		return nil, err
+	}
+	res := []*node{}
+	for _, i := range rangeIndices(len(elems), frm, to, step) {
+		res = append(res, elems[i])
+	}
+	return res, nil
+}
+
+//与get_wildcard相同，对象的成员按键值排序
+func get_wildcard_nodes(nodes []*node, multi bool) ([]*node, error) {
+	if multi {
+		res := []*node{}
+		for _, n := range nodes {
+			res = append(res, children(n)...)
+		}
+		return res, nil
+	}
+	if nodes[0].value == nil {
+		return nil, ErrGetFromNullObj
+	}
+	res := children(nodes[0])
+	if res == nil {
+		return nil, fmt.Errorf("object is not map or slice")
+	}
+	return res, nil
+}
+
+//与get_union相同，对象上只取存在的键值，数组上取存在的下标和每个元素中的键值
+func get_union_nodes(nodes []*node, multi bool, args []interface{}) ([]*node, error) {
+	res := []*node{}
+	if !multi {
+		n := nodes[0]
+		if n.value == nil {
+			return nil, ErrGetFromNullObj
+		}
+		if isObject(n.value) {
+			for _, arg := range args {
+				if name, ok := arg.(string); ok {
+					found, _ := key_children(n, name, false, false)
+					res = append(res, found...)
+				}
+			}
+			return res, nil
+		}
+	}
+	elems, err := elem_nodes(nodes, multi, false)
+	if err != nil {
+		return nil, fmt.Errorf("object is not map or slice")
+	}
+	for _, arg := range args {
+		switch v := arg.(type) {
+		case string:
+			for _, elem := range elems {
+				found, _ := key_children(elem, v, false, false)
+				res = append(res, found...)
+			}
+		case int:
+			if v < 0 {
+				v += len(elems)
+			}
+			if v >= 0 && v < len(elems) {
+				res = append(res, elems[v])
+			}
+		}
+	}
+	return res, nil
+}
+
+//与get_filtered相同，数组上过滤每个元素，对象上判断对象本身
+//列表中的每个节点分别按节点的类型处理，不是数组或对象的节点忽略
+func get_filtered_nodes(nodes []*node, multi bool, root interface{}, filter *filterExpr) ([]*node, error) {
+	var candidates []*node
+	if multi {
+		candidates = []*node{}
+		for _, n := range nodes {
+			if isObject(n.value) {
+				candidates = append(candidates, n)
+			} else {
+				candidates = append(candidates, children(n)...)
+			}
+		}
+	} else {
+		if nodes[0].value == nil {
+			return nil, ErrGetFromNullObj
+		}
+		if isObject(nodes[0].value) {
+			candidates = nodes
+		}
+	}
+	if candidates == nil {
+		var err error
+		candidates, err = elem_nodes(nodes, multi, false)
+		if err != nil {
+			return nil, fmt.Errorf("don't support filter on this type: %T", nodes[0].value)
+		}
+	}
+	res := []*node{}
+	for _, n := range candidates {
+		ok, err := eval_filter(n.value, root, filter)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			res = append(res, n)
+		}
+	}
+	return res, nil
+}
+
+//与get_descendants和get_recursion相同，在nodes和它们的所有后代节点上执行'..'步骤s
+func get_scan_nodes(nodes []*node, multi bool, root interface{}, s step) ([]*node, error) {
+	if !multi && nodes[0].value == nil {
+		return nil, ErrGetFromNullObj
+	}
+	return scan_select(scan_matches(nodes, root, []step{s})[0], s)
+}
+
+//在一次遍历中找到多个'..'步骤的匹配项，返回每个步骤的匹配项，与分别遍历的结果相同
+//与get_descendants和get_recursion的顺序相同，先处理当前节点，再按键值排序依次处理每个成员
+//步骤有key时(例如$..name)在每个对象上取key，没有key时(例如$..*、$..[0])在每个节点上执行args中的选择器
+func scan_matches(nodes []*node, root interface{}, steps []step) [][]*node {
+	res := make([][]*node, len(steps))
+	for i := range res {
+		res[i] = []*node{}
+	}
+	var walk func(n *node)
+	walk = func(n *node) {
+		for i, s := range steps {
+			if len(s.key) > 0 {
+				if v, ok := objectGet(n.value, s.key); ok {
+					res[i] = append(res[i], &node{value: v, key: s.key, parent: n})
+				}
+				continue
+			}
+			for _, k := range select_keys(n.value, root, s.args.(step)) {
+				res[i] = append(res[i], &node{value: child_value(n.value, k), key: k, parent: n})
+			}
+		}
+		for _, child := range children(n) {
+			walk(child)
+		}
+	}
+	for _, n := range nodes {
+		walk(n)
+	}
+	return res
+}
+
+//步骤有key并且args为范围或下标时(例如$..name[0])，从所有匹配项中选择
+func scan_select(matches []*node, s step) ([]*node, error) {
+	if len(s.key) == 0 {
+		return matches, nil
+	}
+	switch argsv := s.args.(type) {
+	case nil:
+		return matches, nil
+	case [3]interface{}:
+		return get_range_nodes(matches, true, argsv[0], argsv[1], argsv[2], false)
+	case []int:
+		return get_idx_nodes(matches, true, argsv, false)
+	default:
+		return nil, fmt.Errorf("range args length should be 3 or 1")
+	}
+}
+
+//节点在数据中的位置，父节点是同一个对象或数组并且键值相同的节点是同一个位置
+//比较位置不需要像path()一样拼接字符串，根节点为零值
+type nodeLoc struct {
+	parent uintptr
+	key    interface{}
+}
+
+func (n *node) loc() nodeLoc {
+	if n.parent == nil {
+		return nodeLoc{}
+	}
+	return nodeLoc{parent: reflect.ValueOf(n.parent.value).Pointer(), key: n.key}
+}
+
+//把节点的值写入父节点，父节点是数组且下标超出长度时加长数组并写回数组的父节点
+//父节点是查找时新建的对象或数组时，把父节点也写入它的父节点
+func set_node(n *node, v interface{}) error {
+	if n.parent == nil {
+		return fmt.Errorf("cannot set value of root node")
+	}
+	switch p := n.parent.value.(type) {
+	case map[string]interface{}:
+		p[n.key.(string)] = v
+	case []interface{}:
+		idx := n.key.(int)
+		if idx >= len(p) {
+			p = append(p, make([]interface{}, idx+1-len(p))...)
+			if err := set_node(n.parent, p); err != nil {
+				return err
+			}
+		}
+		p[idx] = v
+	default:
+		return fmt.Errorf("cannot set value in %T", n.parent.value)
+	}
+	n.value = v
+	return attach_node(n.parent)
+}
+
+//create为true时新建的对象和数组只保存在节点中，写入第一个值时才写入数据
+//查找出错或者没有写入任何值时数据不会被修改
+func attach_node(n *node) error {
+	if n.parent == nil || n.value == nil {
+		return nil
+	}
+	switch p := n.parent.value.(type) {
+	case map[string]interface{}:
+		if p[n.key.(string)] != nil {
+			return nil
+		}
+	case []interface{}:
+		if idx := n.key.(int); idx < len(p) && p[idx] != nil {
+			return nil
+		}
+	default:
+		return nil
+	}
+	return set_node(n, n.value)
+}
@@ -0,0 +1,268 @@
+package jsonpath
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func Test_jsonpath_lookup_nodes(t *testing.T) {
+	tcases := []struct {
+		Path  string
+		RFC   bool
+		Paths []string
+	}{
+		{"$.store.book[0].price", false, []string{`$['store']['book'][0]['price']`}},
+		{"$.store.book[-1].title", false, []string{`$['store']['book'][3]['title']`}},
+		{"$.store.book[1:2].price", false, []string{`$['store']['book'][1]['price']`, `$['store']['book'][2]['price']`}},
+		{"$.store.book.isbn", false, []string{`$['store']['book'][2]['isbn']`, `$['store']['book'][3]['isbn']`}},
+		{"$.store.bicycle.*", false, []string{`$['store']['bicycle']['color']`, `$['store']['bicycle']['price']`}},
+		{"$.store.book[?(@.price > 20)].author", false, []string{`$['store']['book'][3]['author']`}},
+		{"$.store.bicycle['color','size']", false, []string{`$['store']['bicycle']['color']`}},
+		{"$..price[-1]", false, []string{`$['store']['book'][3]['price']`}},
+		{"$.store..[?(@.price > 20)]", false, []string{`$['store']['book'][3]`}},
+		{"$", false, []string{`$`}},
+		{"$.store.book[1:2].price", true, []string{`$['store']['book'][1]['price']`}},
+		{"$..price", true, []string{`$['store']['bicycle']['price']`, `$['store']['book'][0]['price']`, `$['store']['book'][1]['price']`, `$['store']['book'][2]['price']`, `$['store']['book'][3]['price']`}},
+		{"$.missing", true, []string{}},
+	}
+	for idx, tcase := range tcases {
+		var c *Compiled
+		if tcase.RFC {
+			c = MustCompileRFC9535(tcase.Path)
+		} else {
+			c = MustCompile(tcase.Path)
+		}
+		nodes, err := c.LookupNodes(json_data)
+		if err != nil {
+			t.Errorf("idx: %d, path: %s, error: %v", idx, tcase.Path, err)
+			continue
+		}
+		paths := []string{}
+		for _, n := range nodes {
+			paths = append(paths, n.Path)
+		}
+		if !reflect.DeepEqual(paths, tcase.Paths) {
+			t.Errorf("idx: %d, path: %s, (got)%v != (exp)%v", idx, tcase.Path, paths, tcase.Paths)
+		}
+	}
+}
+
+//Compile模式下LookupNodes的值与Lookup的结果一致，Lookup出错时同样出错
+func Test_jsonpath_lookup_nodes_same_as_lookup(t *testing.T) {
+	paths := []string{
+		"$.expensive",
+		"$.store.book[0]",
+		"$.store.book[0,2].title",
+		"$.store.book[:].price",
+		"$.store.book[::-2].author",
+		"$.store.book.author",
+		"$.store.book[*].author[1]",
+		"$.store.*",
+		"$.store.*.price",
+		"$.store.*.tags",
+		"$.store.book[*].*",
+		"$.store.book['x', 0]",
+		"$.store.book[?(@.price < $.expensive)].price",
+		"$.store.book[?(@.author =~ /(?i).*REES/)]",
+		"$.store.bicycle[?(@.color == 'red')]",
+		"$..author",
+		"$..price[1:2]",
+		"$..[0].title",
+		"$..*",
+		"$.store.book[9]",
+		"$.missing",
+		"$.expensive.a",
+		"$.expensive[0]",
+	}
+	for idx, path := range paths {
+		c := MustCompile(path)
+		res, err := c.Lookup(json_data)
+		nodes, nodes_err := c.LookupNodes(json_data)
+		if (err == nil) != (nodes_err == nil) {
+			t.Errorf("idx: %d, path: %s, (got)%v != (exp)%v", idx, path, nodes_err, err)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		values := []interface{}{}
+		for _, n := range nodes {
+			values = append(values, n.Value)
+		}
+		if len(values) == 1 && reflect.DeepEqual(values[0], res) {
+			continue
+		}
+		if !reflect.DeepEqual(values, res) {
+			t.Errorf("idx: %d, path: %s, (got)%v != (exp)%v", idx, path, values, res)
+		}
+	}
+}
+
+//先记录会被脱敏的字段，脱敏后这些位置的值都被修改，其它位置不变
+func Test_jsonpath_lookup_nodes_before_operate(t *testing.T) {
+	var j interface{}
+	json.Unmarshal([]byte(`{"user": {"phone": "13800138000", "contact": {"phone": "13900139000"}}, "list": [{"phone": "13700137000"}, {"id": 1}]}`), &j)
+	nodes, err := JsonPathLookUpNodes(j, "$..phone[1:]")
+	if err != nil {
+		t.Fatal(err)
+	}
+	paths := []string{}
+	for _, n := range nodes {
+		paths = append(paths, n.Path)
+	}
+	expect := []string{`$['user']['phone']`, `$['user']['contact']['phone']`}
+	if !reflect.DeepEqual(paths, expect) {
+		t.Fatalf("(got)%v != (exp)%v", paths, expect)
+	}
+	if _, err := JsonPathLookUpAndDesensitization(j, "$..phone[1:]", PhoneDesensitization); err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range []string{"$.user.phone", "$.user.contact.phone"} {
+		res, _ := JsonPathLookUp(j, path)
+		if s, _ := res.(string); s[3:7] != "****" {
+			t.Errorf("%s should be desensitized, got: %v", path, res)
+		}
+	}
+	if res, _ := JsonPathLookUp(j, "$.list[0].phone"); res != "13700137000" {
+		t.Errorf("$.list[0].phone should not be desensitized, got: %v", res)
+	}
+}
+
+//把值替换成#并计数
+type markOperator struct {
+	count *int
+}
+
+func (m markOperator) Apply(parent interface{}, key interface{}, value interface{}) (interface{}, bool, error) {
+	*m.count++
+	return "#", false, nil
+}
+
+//LookupAndApply处理的节点与LookupNodes返回的节点相同，LookupNodes出错时同样出错
+func Test_jsonpath_lookup_nodes_same_as_operate(t *testing.T) {
+	tcases := []struct {
+		Doc   string
+		Paths []string
+	}{
+		{`{"a": [{"phone": "1", "t": "home"}, {"t": "work"}, {"phone": "2", "t": "home"}], "b": [["x", "y"], ["z"]], "c": {"d": "e", "f": null}}`, []string{
+			"$.a[*].phone", "$.a.phone", "$.a[0,2]", "$.a[-1:]", "$.a[?(@.t == 'home')].phone",
+			"$.b[*][*]", "$.b[0][0]", "$.b.*[0]", "$.b[1:][0]", "$..phone", "$..phone[1]", "$..t[?(@ == 'work')]",
+			"$.c.*", "$.c['d','f']", "$.a.x", "$.c.f[0]", "$.c.f.*", "$.c.x",
+		}},
+		{`[{"p": 1}, {"q": 2}, ["r"]]`, []string{"$[*]", "$[0]", "$[-1][0]", "$[0:1]", "$[0,2]", "$[*].p", "$[?(@.q)]", "$..[0]"}},
+	}
+	for _, tcase := range tcases {
+		for _, path := range tcase.Paths {
+			var obj interface{}
+			json.Unmarshal([]byte(tcase.Doc), &obj)
+			c := MustCompile(path)
+			nodes, nodesErr := c.LookupNodes(obj)
+			count := 0
+			res, err := c.LookupAndApply(obj, markOperator{&count})
+			if (nodesErr == nil) != (err == nil) {
+				t.Errorf("path: %s, LookupNodes error: %v, LookupAndApply error: %v", path, nodesErr, err)
+				continue
+			}
+			if err != nil {
+				continue
+			}
+			if count != len(nodes) {
+				t.Errorf("path: %s, %d nodes, %d operated", path, len(nodes), count)
+			}
+			for _, n := range nodes {
+				if v, _ := JsonPathLookUp(res, n.Path); v != "#" {
+					t.Errorf("path: %s, %s not operated: %v", path, n.Path, toString(res))
+				}
+			}
+		}
+	}
+}
+
+func Test_jsonpath_node_list_mode(t *testing.T) {
+	tcases := []struct {
+		Path   string
+		Expect interface{}
+	}{
+		{"$.expensive", []interface{}{10.0}},
+		{"$.store.bicycle.color", []interface{}{"red"}},
+		{"$.store.book[0].price", []interface{}{8.95}},
+		{"$.store.book[*].isbn", []interface{}{"0-553-21311-3", "0-395-19395-8"}},
+		{"$.store.book[?(@.price > 100)]", []interface{}{}},
+	}
+	for idx, tcase := range tcases {
+		res, err := MustCompile(tcase.Path, WithNodeList()).Lookup(json_data)
+		if err != nil || !reflect.DeepEqual(res, tcase.Expect) {
+			t.Errorf("idx: %d, path: %s, (got)%v != (exp)%v, err: %v", idx, tcase.Path, res, tcase.Expect, err)
+		}
+	}
+	if _, err := MustCompile("$.missing", WithNodeList()).Lookup(json_data); err == nil {
+		t.Errorf("missing key should still return error")
+	}
+}
+
+func Test_jsonpath_lookup_one(t *testing.T) {
+	tcases := []struct {
+		Path   string
+		RFC    bool
+		Expect interface{}
+		Err    error
+	}{
+		{"$.store.bicycle.color", false, "red", nil},
+		{"$.store.book[?(@.price > 20)].title", false, "The Lord of the Rings", nil},
+		{"$.store.book[*].title", false, nil, ErrNotSingleValue},
+		{"$.store.book[?(@.price > 100)]", false, nil, ErrNotSingleValue},
+		{"$.store.bicycle.color", true, "red", nil},
+		{"$.missing", true, nil, ErrNotSingleValue},
+	}
+	for idx, tcase := range tcases {
+		var c *Compiled
+		if tcase.RFC {
+			c = MustCompileRFC9535(tcase.Path)
+		} else {
+			c = MustCompile(tcase.Path)
+		}
+		res, err := c.LookupOne(json_data)
+		if err != tcase.Err || res != tcase.Expect {
+			t.Errorf("idx: %d, path: %s, (got)%v %v != (exp)%v %v", idx, tcase.Path, res, err, tcase.Expect, tcase.Err)
+		}
+	}
+}
+
+func Test_jsonpath_strict_keys(t *testing.T) {
+	tcases := []struct {
+		Path   string
+		Expect interface{}
+		Err    string
+	}{
+		{"$.store.book[*].title", []interface{}{"Sayings of the Century", "Sword of Honour", "Moby Dick", "The Lord of the Rings"}, ""},
+		{"$.store.book.author[0]", "Nigel Rees", ""},
+		{"$.store.book[*].isbn", nil, "key error: isbn not found in $['store']['book'][0]"},
+		{"$.store.book.isbn", nil, "key error: isbn not found in $['store']['book'][0]"},
+		{"$.store.*.color", nil, "key error: color not found in $['store']['book'][0]"},
+		{"$.store.book[?(@.isbn)].isbn", []interface{}{"0-553-21311-3", "0-395-19395-8"}, ""},
+	}
+	for idx, tcase := range tcases {
+		res, err := MustCompile(tcase.Path, WithStrictKeys()).Lookup(json_data)
+		if tcase.Err != "" {
+			if err == nil || err.Error() != tcase.Err {
+				t.Errorf("idx: %d, path: %s, (got)%v != (exp)%v", idx, tcase.Path, err, tcase.Err)
+			}
+			continue
+		}
+		if err != nil || !reflect.DeepEqual(res, tcase.Expect) {
+			t.Errorf("idx: %d, path: %s, (got)%v != (exp)%v, err: %v", idx, tcase.Path, res, tcase.Expect, err)
+		}
+	}
+
+	//出错时不修改数据
+	var j interface{}
+	json.Unmarshal([]byte(`{"list": [{"phone": "13800138000"}, {"id": 1}]}`), &j)
+	_, err := MustCompile("$.list.phone", WithStrictKeys()).LookupAndOperate(j, DataFieldControl, "")
+	if err == nil {
+		t.Errorf("strict keys should return error")
+	}
+	if res, _ := JsonPathLookUp(j, "$.list[0].phone"); res != "13800138000" {
+		t.Errorf("data should not be modified, got: %v", j)
+	}
+}
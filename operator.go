@@ -0,0 +1,270 @@
+package jsonpath
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+)
+
+//LookupAndApply对每个匹配到的值执行的操作
+//parent为值所在的map[string]interface{}或[]interface{}，key为对应的键(string)或下标(int)
+//remove为true时删除该值，否则用newValue替换原来的值
+type Operator interface {
+	Apply(parent interface{}, key interface{}, value interface{}) (newValue interface{}, remove bool, err error)
+}
+
+//删除匹配到的值
+type DeleteOperator struct{}
+
+func (DeleteOperator) Apply(parent interface{}, key interface{}, value interface{}) (interface{}, bool, error) {
+	return nil, true, nil
+}
+
+//脱敏时遇到不是字符串的值的处理方式
+type NonStringMode int
+
+const (
+	//默认的处理方式，MaskOperator保持原值，HashOperator、TokenizeOperator和DetokenizeOperator把数字转换成字符串
+	NonStringDefault NonStringMode = iota
+	//保持原值
+	NonStringSkip
+	//数字和json.Number转换成字符串后脱敏，null等其它值保持原值
+	NonStringStringify
+	//返回*MaskTypeError
+	NonStringError
+)
+
+//用DesensitizationFuncs中名为Func的函数脱敏匹配到的值
+//map成员和数组元素中的字符串都会被脱敏，其它值按NonString处理
+type MaskOperator struct {
+	Func      string
+	NonString NonStringMode
+}
+
+func (m MaskOperator) Apply(parent interface{}, key interface{}, value interface{}) (interface{}, bool, error) {
+	desensitFunc, ok := DesensitizationFuncs[m.Func]
+	if !ok {
+		return nil, false, fmt.Errorf("%s not found in function map", m.Func)
+	}
+	str, ok, err := string_operand(nonstring_mode(m.NonString, NonStringSkip), key, value)
+	if !ok || err != nil {
+		return value, false, err
+	}
+	//脱敏函数只能修改map成员，数组元素放到临时的map中处理
+	tmp := map[string]interface{}{"value": str}
+	if err := desensitFunc(tmp, "value"); err != nil {
+		return nil, false, err
+	}
+	return tmp["value"], false, nil
+}
+
+//把LookupAndOperate的mode和opertFunc转换成对应的Operator
+func mode_operator(mode string, opertFunc string, nonString NonStringMode) (Operator, error) {
+	switch mode {
+	case DataFieldControl:
+		return DeleteOperator{}, nil
+	case DataDesensitizationControl:
+		return MaskOperator{Func: opertFunc, NonString: nonString}, nil
+	}
+	return nil, fmt.Errorf("unknown operate mode: %s", mode)
+}
+
+//把数字转换成字符串，json.Unmarshal得到的float64不使用科学计数法，例如13800138000
+func stringify_number(value interface{}) (string, bool) {
+	switch v := value.(type) {
+	case json.Number:
+		return string(v), true
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), true
+	case float32:
+		return strconv.FormatFloat(float64(v), 'f', -1, 32), true
+	}
+	switch reflect.ValueOf(value).Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return fmt.Sprintf("%d", value), true
+	}
+	return "", false
+}
+
+//取出需要处理的字符串，不是字符串时按mode处理，ok为false时保持原值
+func string_operand(mode NonStringMode, key interface{}, value interface{}) (str string, ok bool, err error) {
+	if str, ok = value.(string); ok {
+		return str, true, nil
+	}
+	switch mode {
+	case NonStringStringify:
+		str, ok = stringify_number(value)
+		return str, ok, nil
+	case NonStringError:
+		return "", false, &MaskTypeError{Key: key, Value: value}
+	}
+	return "", false, nil
+}
+
+//mode为NonStringDefault时使用操作自己的默认处理方式def
+func nonstring_mode(mode, def NonStringMode) NonStringMode {
+	if mode == NonStringDefault {
+		return def
+	}
+	return mode
+}
+
+//取出jsonMap[key]中需要脱敏的字符串，不是字符串时返回*MaskTypeError
+func mask_value(jsonMap map[string]interface{}, key string) (string, error) {
+	value, ok := jsonMap[key].(string)
+	if !ok {
+		return "", &MaskTypeError{Key: key, Value: jsonMap[key]}
+	}
+	return value, nil
+}
+
+//需要执行操作的节点
+type opTarget struct {
+	n  *node
+	op Operator
+}
+
+//对targets中的节点执行操作，返回修改后的obj(根节点被替换或删除时返回新的值)
+//同一个位置只处理第一次出现的target，被删除操作选中的节点下面的target不再处理
+//嵌套的节点先于外层的节点处理，外层的操作看到的是内层修改后的值；深度相同时按targets中的顺序处理
+//数组元素在同一深度的节点都处理完后再统一删除，下标始终是查找时数组中的下标
+func apply_targets(obj interface{}, targets []opTarget) (interface{}, error) {
+	type entry struct {
+		opTarget
+		depth int
+	}
+	seen := make(map[nodeLoc]bool, len(targets))
+	deleted := make(map[nodeLoc]bool)
+	entries := make([]entry, 0, len(targets))
+	for _, t := range targets {
+		loc := t.n.loc()
+		if seen[loc] {
+			continue
+		}
+		seen[loc] = true
+		depth := 0
+		for p := t.n.parent; p != nil; p = p.parent {
+			depth++
+		}
+		entries = append(entries, entry{t, depth})
+		if operator_rank(t.op) == rankDelete {
+			deleted[loc] = true
+		}
+	}
+	if len(deleted) > 0 {
+		kept := entries[:0]
+		for _, e := range entries {
+			if !under_deleted(e.n, deleted) {
+				kept = append(kept, e)
+			}
+		}
+		entries = kept
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].depth > entries[j].depth
+	})
+
+	for start := 0; start < len(entries); {
+		end := start
+		for end < len(entries) && entries[end].depth == entries[start].depth {
+			end++
+		}
+		removed := make(map[*node][]int)
+		parents := make(map[nodeLoc]*node)
+		for _, e := range entries[start:end] {
+			n := e.n
+			if n.parent == nil {
+				newv, remove, err := e.op.Apply(nil, nil, obj)
+				if err != nil {
+					return nil, err
+				}
+				if remove {
+					newv = nil
+				}
+				obj = newv
+				continue
+			}
+			switch p := n.parent.value.(type) {
+			case map[string]interface{}:
+				key := n.key.(string)
+				v, ok := p[key]
+				if !ok {
+					continue
+				}
+				newv, remove, err := e.op.Apply(p, key, v)
+				if err != nil {
+					return nil, err
+				}
+				if remove {
+					delete(p, key)
+				} else {
+					p[key] = newv
+				}
+			case []interface{}:
+				idx := n.key.(int)
+				if idx >= len(p) {
+					continue
+				}
+				newv, remove, err := e.op.Apply(p, idx, p[idx])
+				if err != nil {
+					return nil, err
+				}
+				if !remove {
+					p[idx] = newv
+					continue
+				}
+				//不同的target中同一个数组可能是不同的节点
+				loc := n.parent.loc()
+				if parents[loc] == nil {
+					parents[loc] = n.parent
+				}
+				removed[parents[loc]] = append(removed[parents[loc]], idx)
+			}
+		}
+		//删除元素后的数组写回数组所在的位置
+		for arr, indexes := range removed {
+			res := remove_indexes(arr.value.([]interface{}), indexes)
+			if arr.parent == nil {
+				obj = res
+				continue
+			}
+			switch p := arr.parent.value.(type) {
+			case map[string]interface{}:
+				p[arr.key.(string)] = res
+			case []interface{}:
+				p[arr.key.(int)] = res
+			}
+			arr.value = res
+		}
+		start = end
+	}
+	return obj, nil
+}
+
+//节点是否在deleted中某个位置的下面
+func under_deleted(n *node, deleted map[nodeLoc]bool) bool {
+	for p := n.parent; p != nil; p = p.parent {
+		if deleted[p.loc()] {
+			return true
+		}
+	}
+	return false
+}
+
+//删除数组中的多个下标，返回新的数组
+func remove_indexes(arr []interface{}, indexes []int) []interface{} {
+	skip := make(map[int]bool, len(indexes))
+	for _, idx := range indexes {
+		skip[idx] = true
+	}
+	res := make([]interface{}, 0, len(arr))
+	for i, v := range arr {
+		if !skip[i] {
+			res = append(res, v)
+		}
+	}
+	return res
+}
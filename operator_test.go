@@ -0,0 +1,219 @@
+package jsonpath
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+//把字符串转成大写，删除空字符串
+type upperOperator struct{}
+
+func (upperOperator) Apply(parent interface{}, key interface{}, value interface{}) (interface{}, bool, error) {
+	s, ok := value.(string)
+	if !ok {
+		return value, false, nil
+	}
+	if s == "" {
+		return nil, true, nil
+	}
+	return strings.ToUpper(s), false, nil
+}
+
+func Test_jsonpath_lookup_and_apply(t *testing.T) {
+	doc := `{"a": {"b": "x", "c": ""}, "list": ["p", "", {"x": "q"}], "objs": [{"x": "r", "n": 1}, {"x": "", "n": 2}]}`
+	tcases := []struct {
+		Path   string
+		Expect string
+	}{
+		{"$.a.b", `{"a":{"b":"X","c":""},"list":["p","",{"x":"q"}],"objs":[{"x":"r","n":1},{"x":"","n":2}]}`},
+		{"$.a.*", `{"a":{"b":"X"},"list":["p","",{"x":"q"}],"objs":[{"x":"r","n":1},{"x":"","n":2}]}`},
+		{"$.list[0,1]", `{"a":{"b":"x","c":""},"list":["P",{"x":"q"}],"objs":[{"x":"r","n":1},{"x":"","n":2}]}`},
+		{"$.list[0:1]", `{"a":{"b":"x","c":""},"list":["P",{"x":"q"}],"objs":[{"x":"r","n":1},{"x":"","n":2}]}`},
+		{"$.objs.x", `{"a":{"b":"x","c":""},"list":["p","",{"x":"q"}],"objs":[{"x":"R","n":1},{"n":2}]}`},
+		{"$.objs[?(@.n > 1)]", `{"a":{"b":"x","c":""},"list":["p","",{"x":"q"}],"objs":[{"x":"r","n":1},{"x":"","n":2}]}`},
+		{"$..x", `{"a":{"b":"x","c":""},"list":["p","",{"x":"Q"}],"objs":[{"x":"R","n":1},{"n":2}]}`},
+	}
+	for idx, tcase := range tcases {
+		var obj, expect interface{}
+		json.Unmarshal([]byte(doc), &obj)
+		json.Unmarshal([]byte(tcase.Expect), &expect)
+		res, err := JsonPathLookUpAndApply(obj, tcase.Path, upperOperator{})
+		if err != nil || !reflect.DeepEqual(res, expect) {
+			t.Errorf("idx: %d, path: %s, (got)%v != (exp)%v, err: %v", idx, tcase.Path, toString(res), tcase.Expect, err)
+		}
+	}
+}
+
+//内置的Operator与LookupAndOperate的结果一致
+func Test_jsonpath_builtin_operators(t *testing.T) {
+	doc := `{"user": {"phone": "13800138000", "age": 20}, "list": [{"phone": "13900139000"}, {"phone": "13700137000"}]}`
+	paths := []string{"$.user.phone", "$.user.*", "$.list.phone", "$.list[1]", "$..phone", "$..phone[0]", "$.list[?(@.phone)]"}
+	for idx, path := range paths {
+		for _, tcase := range []struct {
+			Mode string
+			Func string
+			Op   Operator
+		}{
+			{DataFieldControl, "", DeleteOperator{}},
+			{DataDesensitizationControl, PhoneDesensitization, MaskOperator{Func: PhoneDesensitization}},
+		} {
+			var obj, expect interface{}
+			json.Unmarshal([]byte(doc), &obj)
+			json.Unmarshal([]byte(doc), &expect)
+			res, err := MustCompile(path).LookupAndApply(obj, tcase.Op)
+			expect, exp_err := MustCompile(path).LookupAndOperate(expect, tcase.Mode, tcase.Func)
+			if (err == nil) != (exp_err == nil) || !reflect.DeepEqual(res, expect) {
+				t.Errorf("idx: %d, path: %s, (got)%v %v != (exp)%v %v", idx, path, toString(res), err, toString(expect), exp_err)
+			}
+		}
+	}
+}
+
+//RFC 9535模式的路径同样可以删除和脱敏，处理的节点与LookupNodes相同
+func Test_jsonpath_operate_rfc9535(t *testing.T) {
+	doc := `{"users": [{"name": "a", "phone": "13800138000", "age": 20}, {"name": "b", "phone": "13900139000", "age": 30}, {"name": "c", "phone": "13700137000", "age": 40}], "phone": "13600136000"}`
+	tcases := []struct {
+		Path   string
+		Mode   string
+		Expect string
+	}{
+		{"$.users[?@.age > 25].phone", DataFieldControl, `{"users": [{"name": "a", "phone": "13800138000", "age": 20}, {"name": "b", "age": 30}, {"name": "c", "age": 40}], "phone": "13600136000"}`},
+		{"$.users[?@.age > 25 && @.name != 'c'].phone", DataDesensitizationControl, `{"users": [{"name": "a", "phone": "13800138000", "age": 20}, {"name": "b", "phone": "139****9000", "age": 30}, {"name": "c", "phone": "13700137000", "age": 40}], "phone": "13600136000"}`},
+		{"$.users[::2]", DataFieldControl, `{"users": [{"name": "b", "phone": "13900139000", "age": 30}], "phone": "13600136000"}`},
+		{"$.users[:0:-1].phone", DataDesensitizationControl, `{"users": [{"name": "a", "phone": "13800138000", "age": 20}, {"name": "b", "phone": "139****9000", "age": 30}, {"name": "c", "phone": "137****7000", "age": 40}], "phone": "13600136000"}`},
+		{"$.users[0,0,-1]", DataFieldControl, `{"users": [{"name": "b", "phone": "13900139000", "age": 30}], "phone": "13600136000"}`},
+		{"$..phone", DataDesensitizationControl, `{"users": [{"name": "a", "phone": "138****8000", "age": 20}, {"name": "b", "phone": "139****9000", "age": 30}, {"name": "c", "phone": "137****7000", "age": 40}], "phone": "136****6000"}`},
+		{"$[?@ == '13600136000']", DataFieldControl, `{"users": [{"name": "a", "phone": "13800138000", "age": 20}, {"name": "b", "phone": "13900139000", "age": 30}, {"name": "c", "phone": "13700137000", "age": 40}]}`},
+		{"$.users[?length(@.name) > 1]", DataFieldControl, doc},
+	}
+	for idx, tcase := range tcases {
+		var obj, expect interface{}
+		json.Unmarshal([]byte(doc), &obj)
+		json.Unmarshal([]byte(tcase.Expect), &expect)
+		res, err := MustCompileRFC9535(tcase.Path).LookupAndOperate(obj, tcase.Mode, PhoneDesensitization)
+		if err != nil || !reflect.DeepEqual(res, expect) {
+			t.Errorf("idx: %d, path: %s, (got)%v != (exp)%v, err: %v", idx, tcase.Path, toString(res), tcase.Expect, err)
+		}
+	}
+
+	//编译选项中的WithNonStringMask同样生效
+	var obj interface{}
+	json.Unmarshal([]byte(doc), &obj)
+	if _, err := MustCompileRFC9535("$.users[0].age", WithNonStringMask(NonStringError)).LookupAndOperate(obj, DataDesensitizationControl, PhoneDesensitization); err == nil {
+		t.Errorf("non-string error not raised")
+	}
+}
+
+func Test_jsonpath_operator_error(t *testing.T) {
+	var obj interface{}
+	json.Unmarshal([]byte(`{"phone": "13800138000"}`), &obj)
+	if _, err := MustCompile("$.phone").LookupAndOperate(obj, "unknown", ""); err == nil {
+		t.Errorf("unknown mode should return error")
+	}
+	if _, err := JsonPathLookUpAndApply(obj, "$.phone", MaskOperator{Func: "unknown"}); err == nil {
+		t.Errorf("unknown desensitization function should return error")
+	}
+}
+
+func Test_jsonpath_mask_non_string(t *testing.T) {
+	doc := `{"phone": 13800138000, "tel": 8888888, "mobile": "13900139000", "fax": null, "ok": true, "list": [13700137000, null]}`
+	tcases := []struct {
+		Mode   NonStringMode
+		Path   string
+		Expect string
+		Err    bool
+	}{
+		{NonStringSkip, "$.*", `{"phone": 13800138000, "tel": 8888888, "mobile": "139****9000", "fax": null, "ok": true, "list": [13700137000, null]}`, false},
+		{NonStringSkip, "$.list[*]", doc, false},
+		{NonStringStringify, "$.phone", `{"phone": "138****8000", "tel": 8888888, "mobile": "13900139000", "fax": null, "ok": true, "list": [13700137000, null]}`, false},
+		{NonStringStringify, "$.*", `{"phone": "138****8000", "tel": "8****88", "mobile": "139****9000", "fax": null, "ok": true, "list": [13700137000, null]}`, false},
+		{NonStringStringify, "$.list[*]", `{"phone": 13800138000, "tel": 8888888, "mobile": "13900139000", "fax": null, "ok": true, "list": ["137****7000", null]}`, false},
+		{NonStringError, "$.mobile", `{"phone": 13800138000, "tel": 8888888, "mobile": "139****9000", "fax": null, "ok": true, "list": [13700137000, null]}`, false},
+		{NonStringError, "$.phone", "", true},
+		{NonStringError, "$.fax", "", true},
+		{NonStringError, "$.list[1]", "", true},
+	}
+	for idx, tcase := range tcases {
+		var j, expect interface{}
+		json.Unmarshal([]byte(doc), &j)
+		res, err := MustCompile(tcase.Path, WithNonStringMask(tcase.Mode)).LookupAndOperate(j, DataDesensitizationControl, PhoneDesensitization)
+		if tcase.Err {
+			if _, ok := err.(*MaskTypeError); !ok {
+				t.Errorf("idx: %d, path: %s, should return *MaskTypeError, got: %v", idx, tcase.Path, err)
+			}
+			continue
+		}
+		json.Unmarshal([]byte(tcase.Expect), &expect)
+		if err != nil || !reflect.DeepEqual(res, expect) {
+			t.Errorf("idx: %d, path: %s, (got)%v != (exp)%v, err: %v", idx, tcase.Path, toString(res), tcase.Expect, err)
+		}
+	}
+
+	//json.Number和其它数字类型
+	for idx, v := range []interface{}{json.Number("13800138000"), 13800138000, int64(13800138000), uint64(13800138000)} {
+		res, _, err := MaskOperator{Func: PhoneDesensitization, NonString: NonStringStringify}.Apply(nil, 0, v)
+		if err != nil || res != "138****8000" {
+			t.Errorf("idx: %d, value: %v, (got)%v != (exp)138****8000, err: %v", idx, v, res, err)
+		}
+	}
+
+	//直接调用脱敏函数时同样返回错误而不是panic
+	for name, f := range DesensitizationFuncs {
+		jsonMap := map[string]interface{}{"v": 13800138000.0}
+		err := f(jsonMap, "v")
+		if _, ok := err.(*MaskTypeError); !ok {
+			t.Errorf("%s should return *MaskTypeError, got: %v", name, err)
+		}
+	}
+	err := &MaskTypeError{Key: "phone", Value: 13800138000.0}
+	if err.Error() != "mask error: value of phone is number, not string" {
+		t.Errorf("unexpected error message: %s", err.Error())
+	}
+}
+
+//路径中间的值为null或者类型不对时返回错误而不是panic，数据不被修改
+func Test_jsonpath_operate_null_intermediate(t *testing.T) {
+	doc := `{"phones": null, "user": null, "name": "x", "list": [null, {"phone": "13800138000"}]}`
+	tcases := []struct {
+		Path string
+		Err  error
+	}{
+		{"$.phones[0]", ErrGetFromNullObj},
+		{"$.phones[0,1]", ErrGetFromNullObj},
+		{"$.phones[0:1]", ErrGetFromNullObj},
+		{"$.phones[*]", ErrGetFromNullObj},
+		{"$.phones.*", ErrGetFromNullObj},
+		{"$.phones[?(@.t)]", ErrGetFromNullObj},
+		{"$.user.phone", ErrGetFromNullObj},
+		{"$.list[0].phone", ErrGetFromNullObj},
+		{"$.list[0][0]", ErrGetFromNullObj},
+		{"$.name[0]", nil},
+		{"$.name[0:1]", nil},
+		{"$.name.*", nil},
+	}
+	for idx, tcase := range tcases {
+		for _, mode := range []string{DataFieldControl, DataDesensitizationControl} {
+			var j, expect interface{}
+			json.Unmarshal([]byte(doc), &j)
+			json.Unmarshal([]byte(doc), &expect)
+			_, err := MustCompile(tcase.Path).LookupAndOperate(j, mode, PhoneDesensitization)
+			if err == nil || (tcase.Err != nil && err != tcase.Err) {
+				t.Errorf("idx: %d, path: %s, mode: %s, unexpected error: %v", idx, tcase.Path, mode, err)
+			}
+			if !reflect.DeepEqual(j, expect) {
+				t.Errorf("idx: %d, path: %s, mode: %s, data modified: %v", idx, tcase.Path, mode, toString(j))
+			}
+		}
+	}
+
+	//null元素上取键值时忽略该元素
+	var j, expect interface{}
+	json.Unmarshal([]byte(doc), &j)
+	json.Unmarshal([]byte(`{"phones": null, "user": null, "name": "x", "list": [null, {"phone": "138****8000"}]}`), &expect)
+	res, err := JsonPathLookUpAndDesensitization(j, "$.list[*].phone", PhoneDesensitization)
+	if err != nil || !reflect.DeepEqual(res, expect) {
+		t.Errorf("(got)%v != (exp)%v, err: %v", toString(res), toString(expect), err)
+	}
+}
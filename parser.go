@@ -0,0 +1,481 @@
+package jsonpath
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+//jsonpath解析后的语法树
+//path 原始的jsonpath字符串
+//root 路径的起始符号，'$' 或 '@'
+//segments 路径中依次出现的段
+type pathNode struct {
+	path     string
+	root     string
+	segments []segment
+}
+
+//路径中的一个段，例如 .name、[0,1]、..name
+//descendant 是否是'..'递归段
+//dotted 是否是用'.'书写的段(.name 或 .*)
+//selectors 段中的选择器列表
+//pos 段在jsonpath中的字节偏移
+type segment struct {
+	descendant bool
+	dotted     bool
+	selectors  []selector
+	pos        int
+}
+
+//段中的单个选择器
+//kind 选择器类型(必须，有:name,wildcard,index,slice,filter)
+//name name选择器的键值
+//index index选择器的下标
+//slice slice选择器的(start, end, step)，未填写的部分为nil
+//expr filter选择器解析后的过滤表达式
+type selector struct {
+	kind  string
+	name  string
+	index int
+	slice [3]interface{}
+	expr  *filterExpr
+	pos   int
+}
+
+//路径的文本形式，用于调试和测试
+//能用'.'书写的名字使用'.'，其它选择器使用中括号
+func (n *pathNode) String() string {
+	var sb strings.Builder
+	sb.WriteString(n.root)
+	for _, seg := range n.segments {
+		if seg.descendant {
+			sb.WriteString("..")
+		}
+		if sel := seg.selectors[0]; len(seg.selectors) == 1 &&
+			(sel.kind == "wildcard" || (sel.kind == "name" && isMemberNameShorthand(sel.name))) {
+			if !seg.descendant {
+				sb.WriteString(".")
+			}
+			sb.WriteString(sel.String())
+			continue
+		}
+		sels := make([]string, len(seg.selectors))
+		for i, sel := range seg.selectors {
+			sels[i] = sel.String()
+			if sel.kind == "name" {
+				sels[i] = "'" + escapeName(sel.name) + "'"
+			}
+		}
+		sb.WriteString("[" + strings.Join(sels, ",") + "]")
+	}
+	return sb.String()
+}
+
+//选择器的文本形式，name选择器为不加引号的键值
+func (s selector) String() string {
+	switch s.kind {
+	case "name":
+		return s.name
+	case "wildcard":
+		return "*"
+	case "index":
+		return strconv.Itoa(s.index)
+	case "slice":
+		parts := []string{}
+		for i, v := range s.slice {
+			if i == 2 && v == nil {
+				break
+			}
+			if v == nil {
+				parts = append(parts, "")
+			} else {
+				parts = append(parts, strconv.Itoa(v.(int)))
+			}
+		}
+		return strings.Join(parts, ":")
+	case "filter":
+		return "?" + s.expr.String()
+	}
+	return ""
+}
+
+//递归下降解析器
+//path 输入的jsonpath字符串
+//tokens 词法分析的结果
+//cur 当前处理到的词法单元下标
+//rfc 是否按照RFC 9535的语法严格解析
+type parser struct {
+	path   string
+	tokens []lexToken
+	cur    int
+	rfc    bool
+}
+
+//RFC 9535中整数的取值范围
+const maxSafeInteger = 1<<53 - 1
+
+//解析jsonpath，返回语法树
+func parsePath(path string) (*pathNode, error) {
+	return parse(path, false)
+}
+
+//按RFC 9535语法解析jsonpath，返回语法树
+func parsePathRFC9535(path string) (*pathNode, error) {
+	return parse(path, true)
+}
+
+func parse(path string, rfc bool) (*pathNode, error) {
+	tokens, err := lex(path, rfc)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{path: path, tokens: tokens, rfc: rfc}
+	if rfc && len(path) > 0 && strings.ContainsRune(blankChars, rune(path[0])) {
+		return nil, newSyntaxError(path, 0, path[:1], "leading blank is not allowed", tokRoot.String())
+	}
+	node, err := p.parseQuery()
+	if err != nil {
+		return nil, err
+	}
+	if tok := p.peek(); tok.kind != tokEOF {
+		return nil, p.fail(tok, "unexpected "+p.describe(tok), "'.'", "'..'", "'['")
+	}
+	if rfc && len(path) > 0 && strings.ContainsRune(blankChars, rune(path[len(path)-1])) {
+		return nil, newSyntaxError(path, len(path)-1, path[len(path)-1:], "trailing blank is not allowed")
+	}
+	if rfc && node.root != "$" {
+		return nil, newSyntaxError(path, 0, node.root, "path should start with '$'", tokRoot.String())
+	}
+	return node, nil
+}
+
+func (p *parser) peek() lexToken {
+	return p.tokens[p.cur]
+}
+
+//向后查看第n个词法单元
+func (p *parser) peekAt(n int) lexToken {
+	if p.cur+n >= len(p.tokens) {
+		return p.tokens[len(p.tokens)-1]
+	}
+	return p.tokens[p.cur+n]
+}
+
+func (p *parser) next() lexToken {
+	tok := p.tokens[p.cur]
+	if tok.kind != tokEOF {
+		p.cur++
+	}
+	return tok
+}
+
+func (p *parser) expect(kind tokenKind) (lexToken, error) {
+	tok := p.next()
+	if tok.kind != kind {
+		return tok, p.fail(tok, "unexpected "+p.describe(tok), kind.String())
+	}
+	return tok, nil
+}
+
+//在tok的位置生成语法错误
+func (p *parser) fail(tok lexToken, msg string, expected ...string) error {
+	return newSyntaxError(p.path, tok.pos, p.path[tok.pos:tok.end], msg, expected...)
+}
+
+//错误信息中对词法单元的描述
+func (p *parser) describe(tok lexToken) string {
+	if tok.kind == tokEOF {
+		return tokEOF.String()
+	}
+	return fmt.Sprintf("'%s'", p.path[tok.pos:tok.end])
+}
+
+//query = ('$' | '@') segment*
+func (p *parser) parseQuery() (*pathNode, error) {
+	tok := p.next()
+	if tok.kind != tokRoot && tok.kind != tokCurrent {
+		return nil, p.fail(tok, "path should start with '$' or '@'", tokRoot.String(), tokCurrent.String())
+	}
+	node := &pathNode{path: p.path, root: tok.val, segments: []segment{}}
+	for {
+		tok = p.peek()
+		switch tok.kind {
+		case tokDot:
+			p.next()
+			seg, err := p.parseDotted(tok, false)
+			if err != nil {
+				return nil, err
+			}
+			node.segments = append(node.segments, seg)
+		case tokDotDot:
+			p.next()
+			//多个连续的'..'等同于一个
+			for !p.rfc && (p.peek().kind == tokDotDot || p.peek().kind == tokDot) {
+				p.next()
+			}
+			seg, err := p.parseDotted(tok, true)
+			if err != nil {
+				return nil, err
+			}
+			node.segments = append(node.segments, seg)
+		case tokLBracket:
+			sels, err := p.parseBracket()
+			if err != nil {
+				return nil, err
+			}
+			node.segments = append(node.segments, segment{selectors: sels, pos: tok.pos})
+		default:
+			return node, nil
+		}
+	}
+}
+
+//解析'.'或'..'之后的部分: name、'*' 或 '[...]'
+func (p *parser) parseDotted(dot lexToken, descendant bool) (segment, error) {
+	seg := segment{descendant: descendant, dotted: true, pos: dot.pos}
+	tok := p.peek()
+	if p.rfc && tok.pos != dot.end {
+		return seg, p.fail(tok, "blank is not allowed after "+dot.kind.String())
+	}
+	switch tok.kind {
+	case tokName, tokNumber:
+		if p.rfc && (tok.kind != tokName || !isMemberNameShorthand(tok.val)) {
+			return seg, p.fail(tok, "invalid member name "+p.describe(tok), tokName.String())
+		}
+		p.next()
+		seg.selectors = []selector{{kind: "name", name: tok.val, pos: tok.pos}}
+	case tokStar:
+		p.next()
+		seg.selectors = []selector{{kind: "wildcard", pos: tok.pos}}
+	case tokLBracket:
+		//兼容 `$[0].[0]` 这种写法，RFC 9535中只有'..'后可以跟'['
+		if p.rfc && !descendant {
+			return seg, p.fail(tok, "unexpected "+p.describe(tok)+" after "+dot.kind.String(), tokName.String(), tokStar.String())
+		}
+		sels, err := p.parseBracket()
+		if err != nil {
+			return seg, err
+		}
+		seg.dotted = false
+		seg.selectors = sels
+	default:
+		return seg, p.fail(tok, "unexpected "+p.describe(tok)+" after "+dot.kind.String(), tokName.String(), tokStar.String(), tokLBracket.String())
+	}
+	return seg, nil
+}
+
+//RFC 9535中'.'后可以直接书写的名字: 以字母、'_'或非ASCII字符开头，之后还可以有数字
+func isMemberNameShorthand(name string) bool {
+	for i, r := range name {
+		switch {
+		case r == '_', r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= 0x80:
+		case r >= '0' && r <= '9' && i > 0:
+		default:
+			return false
+		}
+	}
+	return len(name) > 0
+}
+
+//bracket = '[' selector (',' selector)* ']'
+func (p *parser) parseBracket() ([]selector, error) {
+	if _, err := p.expect(tokLBracket); err != nil {
+		return nil, err
+	}
+	sels := []selector{}
+	for {
+		sel, err := p.parseSelector()
+		if err != nil {
+			return nil, err
+		}
+		sels = append(sels, sel)
+		tok := p.next()
+		if tok.kind == tokRBracket {
+			break
+		}
+		if tok.kind != tokComma {
+			return nil, p.fail(tok, "unexpected "+p.describe(tok)+" in brackets", tokComma.String(), tokRBracket.String())
+		}
+	}
+	return sels, nil
+}
+
+func (p *parser) parseSelector() (selector, error) {
+	tok := p.peek()
+	switch tok.kind {
+	case tokStar:
+		p.next()
+		return selector{kind: "wildcard", pos: tok.pos}, nil
+	case tokString:
+		p.next()
+		return selector{kind: "name", name: tok.val, pos: tok.pos}, nil
+	case tokQuestion:
+		//'?(...)'中的括号作为分组处理，两种模式使用相同的语法
+		p.next()
+		expr, err := p.parseLogicalExpr()
+		if err != nil {
+			return selector{}, err
+		}
+		if err := p.checkLogical(expr); err != nil {
+			return selector{}, err
+		}
+		return selector{kind: "filter", expr: expr, pos: tok.pos}, nil
+	case tokNumber, tokColon:
+		return p.parseIndexOrSlice()
+	case tokLParen:
+		return selector{}, p.fail(tok, "script expression is not supported")
+	default:
+		return selector{}, p.fail(tok, "unexpected "+p.describe(tok)+" in brackets", tokString.String(), tokNumber.String(), tokColon.String(), tokStar.String(), tokQuestion.String())
+	}
+}
+
+//index = int
+//slice = [int] ':' [int] [':' [int]]
+func (p *parser) parseIndexOrSlice() (selector, error) {
+	start := p.peek()
+	var bounds [3]interface{}
+	part := 0
+	for {
+		tok := p.peek()
+		switch tok.kind {
+		case tokNumber:
+			p.next()
+			if bounds[part] != nil {
+				return selector{}, p.fail(tok, "unexpected "+p.describe(tok), tokColon.String(), tokRBracket.String())
+			}
+			i, err := p.parseInt(tok)
+			if err != nil {
+				return selector{}, err
+			}
+			bounds[part] = i
+			continue
+		case tokColon:
+			p.next()
+			part++
+			if part > 2 {
+				return selector{}, p.fail(tok, "unexpected "+p.describe(tok)+" in slice", tokNumber.String(), tokRBracket.String())
+			}
+			continue
+		}
+		break
+	}
+	if part == 0 {
+		return selector{kind: "index", index: bounds[0].(int), pos: start.pos}, nil
+	}
+	return selector{kind: "slice", slice: bounds, pos: start.pos}, nil
+}
+
+func (p *parser) parseInt(tok lexToken) (int, error) {
+	i, err := strconv.Atoi(tok.val)
+	if err != nil {
+		return 0, p.fail(tok, "invalid integer "+p.describe(tok))
+	}
+	if p.rfc {
+		//RFC 9535中的整数不能有前导0，不能是-0，且在±(2^53-1)之间
+		digits := strings.TrimPrefix(tok.val, "-")
+		if (len(digits) > 1 && digits[0] == '0') || tok.val == "-0" || i > maxSafeInteger || i < -maxSafeInteger {
+			return 0, p.fail(tok, "invalid integer "+p.describe(tok))
+		}
+	}
+	return i, nil
+}
+
+//将语法树转换成Compiled中执行的steps
+func buildSteps(node *pathNode) ([]step, error) {
+	steps := []step{}
+	segs := node.segments
+	for i := 0; i < len(segs); i++ {
+		seg := segs[i]
+		//'.name' 后紧跟 '[...]' 时合并为一个带key的步骤
+		var next *segment
+		if i+1 < len(segs) && !segs[i+1].dotted && !segs[i+1].descendant {
+			next = &segs[i+1]
+		}
+		sel := seg.selectors[0]
+		switch {
+		case seg.descendant:
+			//'..*' '..[0]' '..[?()]' '..['a','b']' 等在每个后代节点上执行中括号中的选择器
+			if len(seg.selectors) != 1 || sel.kind != "name" {
+				bracket, err := bracketSteps(node, "", seg.selectors)
+				if err != nil {
+					return nil, err
+				}
+				steps = append(steps, step{"scan", "", bracket[0]})
+				continue
+			}
+			if next == nil {
+				steps = append(steps, step{"scan", sel.name, nil})
+				continue
+			}
+			i++
+			bracket, err := bracketSteps(node, "", next.selectors)
+			if err != nil {
+				return nil, err
+			}
+			// 如果后面出现参数范围限制，则添加相应的args
+			if len(bracket) == 1 && (bracket[0].op == "range" || bracket[0].op == "idx") {
+				steps = append(steps, step{"scan", sel.name, bracket[0].args})
+			} else {
+				steps = append(steps, step{"scan", sel.name, nil})
+				steps = append(steps, bracket...)
+			}
+		case seg.dotted && sel.kind == "name":
+			if next == nil {
+				steps = append(steps, step{"key", sel.name, nil})
+				continue
+			}
+			i++
+			bracket, err := bracketSteps(node, sel.name, next.selectors)
+			if err != nil {
+				return nil, err
+			}
+			steps = append(steps, bracket...)
+		default:
+			bracket, err := bracketSteps(node, "", seg.selectors)
+			if err != nil {
+				return nil, err
+			}
+			steps = append(steps, bracket...)
+		}
+	}
+	return steps, nil
+}
+
+//将中括号中的选择器转换成步骤，key为中括号前的键值
+func bracketSteps(node *pathNode, key string, sels []selector) ([]step, error) {
+	sel := sels[0]
+	if len(sels) == 1 {
+		switch sel.kind {
+		case "wildcard":
+			return []step{{"wildcard", key, nil}}, nil
+		case "slice":
+			return []step{{"range", key, sel.slice}}, nil
+		case "filter":
+			return []step{{"filter", key, sel.expr}}, nil
+		case "name":
+			if key == "" {
+				return []step{{"key", sel.name, nil}}, nil
+			}
+			return []step{{"key", key, nil}, {"key", sel.name, nil}}, nil
+		}
+	}
+	//全部是下标时沿用idx步骤，否则是键值和下标混合的union步骤
+	idx := []int{}
+	union := []interface{}{}
+	for _, s := range sels {
+		switch s.kind {
+		case "index":
+			idx = append(idx, s.index)
+			union = append(union, s.index)
+		case "name":
+			union = append(union, s.name)
+		default:
+			return nil, newSyntaxError(node.path, s.pos, "", "only support name and index in union", tokString.String(), tokNumber.String())
+		}
+	}
+	if len(idx) == len(sels) {
+		return []step{{"idx", key, idx}}, nil
+	}
+	return []step{{"union", key, union}}, nil
+}
@@ -0,0 +1,213 @@
+package jsonpath
+
+import (
+	"fmt"
+	"reflect"
+)
+
+//策略中的一条规则
+//Path 规则匹配的jsonpath，Options为编译Path时使用的选项
+//Required 为true时Path在数据中取不到值会返回错误，否则跳过这条规则
+//RFC9535 为true时用CompileRFC9535编译Path，这样的规则没有匹配的节点时视为取不到值
+type PolicyRule struct {
+	Path     string
+	Operator Operator
+	Options  []Option
+	Required bool
+	RFC9535  bool
+}
+
+//一组预先编译好的规则，Apply时把所有规则一次性应用到数据上
+//规则的步骤组成一棵树，多条规则相同的前缀(例如 $.data.items[*])在每次Apply中只执行一次
+//同一个节点下的所有'..'步骤(例如 $..phone、$..email、$..id_card)在一次遍历中找到匹配项
+//所有规则先在原始数据上取得匹配的节点，再统一修改数据，规则之间不会互相影响
+//同一个位置被多条规则选中时只执行优先级最高的操作: 删除 > 摘要 > token > 脱敏 > 其它操作，优先级相同时先出现的规则生效
+//被删除的位置下面的其它操作不再执行；嵌套的位置先于外层的位置处理
+//Policy创建后不会被修改，可以在多个goroutine中同时使用
+type Policy struct {
+	ops     []Operator
+	root    *policyStep
+	queries []policyQuery
+}
+
+//RFC 9535模式的规则，不加入步骤树，每次Apply单独查找
+type policyQuery struct {
+	c        *Compiled
+	rule     int
+	required bool
+}
+
+//规则步骤组成的树中的一个节点
+//rules 在这一步结束的规则，required 子树中是否有Required的规则
+type policyStep struct {
+	s        step
+	strict   bool
+	children []*policyStep
+	rules    []int
+	required bool
+}
+
+//规则选中的节点
+type policyMatch struct {
+	n    *node
+	rule int
+	rank int
+}
+
+//内置操作的优先级，数字越大优先级越高
+const (
+	rankOther = iota
+	rankMask
+	rankTokenize
+	rankHash
+	rankDelete
+)
+
+//编译所有规则
+func NewPolicy(rules ...PolicyRule) (*Policy, error) {
+	p := &Policy{root: &policyStep{}}
+	for i, rule := range rules {
+		if rule.Operator == nil {
+			return nil, fmt.Errorf("policy rule %d: operator is nil", i)
+		}
+		p.ops = append(p.ops, rule.Operator)
+		if rule.RFC9535 {
+			c, err := CompileRFC9535(rule.Path, rule.Options...)
+			if err != nil {
+				return nil, err
+			}
+			p.queries = append(p.queries, policyQuery{c: c, rule: i, required: rule.Required})
+			continue
+		}
+		c, err := Compile(rule.Path, rule.Options...)
+		if err != nil {
+			return nil, err
+		}
+		p.root.add(c, i, rule.Required)
+	}
+	return p, nil
+}
+
+//把规则的步骤加入树中，与已有规则相同的前缀共用树中的节点
+func (ps *policyStep) add(c *Compiled, rule int, required bool) {
+	ps.required = ps.required || required
+	cur := ps
+	for _, s := range c.steps {
+		var next *policyStep
+		for _, child := range cur.children {
+			if child.strict == c.strict && reflect.DeepEqual(child.s, s) {
+				next = child
+				break
+			}
+		}
+		if next == nil {
+			next = &policyStep{s: s, strict: c.strict}
+			cur.children = append(cur.children, next)
+		}
+		next.required = next.required || required
+		cur = next
+	}
+	cur.rules = append(cur.rules, rule)
+}
+
+//对obj应用所有规则，返回修改后的obj(根节点被替换或删除时返回新的值)
+func (p *Policy) Apply(obj interface{}) (interface{}, error) {
+	found := make(map[nodeLoc]*policyMatch)
+	//按找到的先后顺序处理，深度相同的节点处理顺序固定
+	order := []nodeLoc{}
+	if err := p.match(p.root, []*node{{value: obj}}, false, obj, found, &order); err != nil {
+		return nil, err
+	}
+	for _, q := range p.queries {
+		nodes := evalQuery(q.c.query, obj, obj)
+		if len(nodes) == 0 && q.required {
+			return nil, fmt.Errorf("required path %s matched nothing", q.c.path)
+		}
+		p.record(q.rule, nodes, found, &order)
+	}
+	targets := make([]opTarget, 0, len(order))
+	for _, loc := range order {
+		m := found[loc]
+		targets = append(targets, opTarget{n: m.n, op: p.ops[m.rule]})
+	}
+	return apply_targets(obj, targets)
+}
+
+//在nodes上执行树中ps下面的所有步骤，记录每个位置优先级最高的规则
+func (p *Policy) match(ps *policyStep, nodes []*node, multi bool, root interface{}, found map[nodeLoc]*policyMatch, order *[]nodeLoc) error {
+	for _, i := range ps.rules {
+		p.record(i, nodes, found, order)
+	}
+	//所有'..'步骤在一次遍历中找到匹配项，例如$..phone和$..email只遍历一次数据
+	scans := []step{}
+	for _, child := range ps.children {
+		if child.s.op == "scan" {
+			scans = append(scans, child.s)
+		}
+	}
+	var matches [][]*node
+	var scanErr error
+	if len(scans) > 0 {
+		if !multi && nodes[0].value == nil {
+			scanErr = ErrGetFromNullObj
+		} else {
+			matches = scan_matches(nodes, root, scans)
+		}
+	}
+	for _, child := range ps.children {
+		var next []*node
+		var nextMulti bool
+		var err error
+		if child.s.op == "scan" {
+			err = scanErr
+			if err == nil {
+				next, err = scan_select(matches[0], child.s)
+				matches = matches[1:]
+			}
+			nextMulti = true
+		} else {
+			next, nextMulti, err = step_nodes(nodes, multi, root, child.s, child.strict, false)
+		}
+		if err != nil {
+			//取不到值的路径跳过，除非子树中有Required的规则
+			if child.required {
+				return err
+			}
+			continue
+		}
+		if err := p.match(child, next, nextMulti, root, found, order); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//记录规则i选中的节点，同一个位置只保留优先级最高的规则
+func (p *Policy) record(i int, nodes []*node, found map[nodeLoc]*policyMatch, order *[]nodeLoc) {
+	rank := operator_rank(p.ops[i])
+	for _, n := range nodes {
+		loc := n.loc()
+		m, ok := found[loc]
+		if !ok {
+			*order = append(*order, loc)
+		} else if m.rank > rank || (m.rank == rank && m.rule < i) {
+			continue
+		}
+		found[loc] = &policyMatch{n: n, rule: i, rank: rank}
+	}
+}
+
+//内置操作的优先级
+func operator_rank(op Operator) int {
+	switch op.(type) {
+	case DeleteOperator, *DeleteOperator:
+		return rankDelete
+	case HashOperator, *HashOperator:
+		return rankHash
+	case TokenizeOperator, *TokenizeOperator:
+		return rankTokenize
+	case MaskOperator, *MaskOperator:
+		return rankMask
+	}
+	return rankOther
+}
@@ -0,0 +1,262 @@
+package jsonpath
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func Test_jsonpath_policy(t *testing.T) {
+	doc := `{"user": {"name": "张三丰", "phone": "13800138000", "uid": "u1"}, "list": [{"phone": "13900139000", "token": "t1"}, {"phone": "13700137000", "token": "t2"}], "tags": ["a", "", "b", ""]}`
+	//sha256(8字节的0 + "u1")的前8个字符为a7d373dd
+	hash := HashOperator{Length: 8}
+	tcases := []struct {
+		Rules  []PolicyRule
+		Expect string
+	}{
+		//删除优先于脱敏，与规则的顺序无关
+		{[]PolicyRule{
+			{Path: "$..phone", Operator: MaskOperator{Func: PhoneDesensitization}},
+			{Path: "$.user.phone", Operator: DeleteOperator{}},
+		}, `{"user": {"name": "张三丰", "uid": "u1"}, "list": [{"phone": "139****9000", "token": "t1"}, {"phone": "137****7000", "token": "t2"}], "tags": ["a", "", "b", ""]}`},
+		//摘要优先于脱敏
+		{[]PolicyRule{
+			{Path: "$.user.*", Operator: MaskOperator{Func: NameDesensitization}},
+			{Path: "$.user.uid", Operator: hash},
+		}, `{"user": {"name": "张*丰", "phone": "1*********0", "uid": "a7d373dd"}, "list": [{"phone": "13900139000", "token": "t1"}, {"phone": "13700137000", "token": "t2"}], "tags": ["a", "", "b", ""]}`},
+		//优先级相同时先出现的规则生效
+		{[]PolicyRule{
+			{Path: "$.user.phone", Operator: MaskOperator{Func: PhoneDesensitization}},
+			{Path: "$..phone", Operator: MaskOperator{Func: NameDesensitization}},
+		}, `{"user": {"name": "张三丰", "phone": "138****8000", "uid": "u1"}, "list": [{"phone": "1*********0", "token": "t1"}, {"phone": "1*********0", "token": "t2"}], "tags": ["a", "", "b", ""]}`},
+		//被删除的位置下面的规则不再执行
+		{[]PolicyRule{
+			{Path: "$.list[0].phone", Operator: MaskOperator{Func: PhoneDesensitization}},
+			{Path: "$.list[0]", Operator: DeleteOperator{}},
+			{Path: "$.list.token", Operator: DeleteOperator{}},
+		}, `{"user": {"name": "张三丰", "phone": "13800138000", "uid": "u1"}, "list": [{"phone": "13700137000"}], "tags": ["a", "", "b", ""]}`},
+		//同一个数组中的多个元素按原始下标删除
+		{[]PolicyRule{
+			{Path: "$.tags[0]", Operator: DeleteOperator{}},
+			{Path: "$.tags[*]", Operator: upperOperator{}},
+			{Path: "$.list[?(@.token == 't2')]", Operator: DeleteOperator{}},
+		}, `{"user": {"name": "张三丰", "phone": "13800138000", "uid": "u1"}, "list": [{"phone": "13900139000", "token": "t1"}], "tags": ["B"]}`},
+		//不存在的路径跳过
+		{[]PolicyRule{
+			{Path: "$.user.email", Operator: DeleteOperator{}},
+			{Path: "$.user.uid", Operator: DeleteOperator{}},
+		}, `{"user": {"name": "张三丰", "phone": "13800138000"}, "list": [{"phone": "13900139000", "token": "t1"}, {"phone": "13700137000", "token": "t2"}], "tags": ["a", "", "b", ""]}`},
+		//嵌套的位置先处理，外层的操作看到的是修改后的值
+		{[]PolicyRule{
+			{Path: "$.list[*]", Operator: upperOperator{}},
+			{Path: "$.list", Operator: DeleteOperator{}},
+		}, `{"user": {"name": "张三丰", "phone": "13800138000", "uid": "u1"}, "tags": ["a", "", "b", ""]}`},
+		//RFC 9535模式的规则与Compile模式的规则一起按优先级处理
+		{[]PolicyRule{
+			{Path: "$..phone", Operator: MaskOperator{Func: PhoneDesensitization}},
+			{Path: "$.list[?@.token == 't2']", Operator: DeleteOperator{}, RFC9535: true},
+			{Path: "$.tags[1::2]", Operator: DeleteOperator{}, RFC9535: true},
+			{Path: "$.user.phone", Operator: upperOperator{}, RFC9535: true},
+		}, `{"user": {"name": "张三丰", "phone": "138****8000", "uid": "u1"}, "list": [{"phone": "139****9000", "token": "t1"}], "tags": ["a", "b"]}`},
+	}
+	for idx, tcase := range tcases {
+		var obj, expect interface{}
+		json.Unmarshal([]byte(doc), &obj)
+		json.Unmarshal([]byte(tcase.Expect), &expect)
+		p, err := NewPolicy(tcase.Rules...)
+		if err != nil {
+			t.Fatalf("idx: %d, %v", idx, err)
+		}
+		res, err := p.Apply(obj)
+		if err != nil || !reflect.DeepEqual(res, expect) {
+			t.Errorf("idx: %d, (got)%v != (exp)%v, err: %v", idx, toString(res), tcase.Expect, err)
+		}
+	}
+}
+
+//规则之间没有重叠时结果与逐条调用JsonPathLookUpAndApply相同
+func Test_jsonpath_policy_sequential(t *testing.T) {
+	doc := `{"user": {"phone": "13800138000", "idcard": "110101199003077777", "tags": ["x", "y"]}, "list": [{"phone": "13900139000", "n": 1}, {"phone": "13700137000", "n": 2}]}`
+	rules := []PolicyRule{
+		{Path: "$.user.phone", Operator: MaskOperator{Func: PhoneDesensitization}},
+		{Path: "$.user.idcard", Operator: HashOperator{Salt: []byte("s")}},
+		{Path: "$.user.tags[0]", Operator: DeleteOperator{}},
+		{Path: "$.list[?(@.n > 1)].phone", Operator: TokenizeOperator{Key: []byte("k")}},
+		{Path: "$.list[0].n", Operator: DeleteOperator{}},
+	}
+	var obj, expect interface{}
+	json.Unmarshal([]byte(doc), &obj)
+	json.Unmarshal([]byte(doc), &expect)
+	for _, rule := range rules {
+		var err error
+		if expect, err = JsonPathLookUpAndApply(expect, rule.Path, rule.Operator); err != nil {
+			t.Fatal(err)
+		}
+	}
+	p, err := NewPolicy(rules...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := p.Apply(obj)
+	if err != nil || !reflect.DeepEqual(res, expect) {
+		t.Errorf("(got)%v != (exp)%v, err: %v", toString(res), toString(expect), err)
+	}
+}
+
+func Test_jsonpath_policy_error(t *testing.T) {
+	if _, err := NewPolicy(PolicyRule{Path: "$.a"}); err == nil {
+		t.Errorf("nil operator error not raised")
+	}
+	if _, err := NewPolicy(PolicyRule{Path: "$.a.]", Operator: DeleteOperator{}}); err == nil {
+		t.Errorf("syntax error not raised")
+	}
+
+	var obj interface{}
+	json.Unmarshal([]byte(`{"a": {"b": 1}}`), &obj)
+	p, _ := NewPolicy(PolicyRule{Path: "$.a.c", Operator: DeleteOperator{}, Required: true})
+	if _, err := p.Apply(obj); err == nil {
+		t.Errorf("required path error not raised")
+	}
+	p, _ = NewPolicy(PolicyRule{Path: "$.a[?@ > 1]", Operator: DeleteOperator{}, Required: true, RFC9535: true})
+	if _, err := p.Apply(obj); err == nil {
+		t.Errorf("required RFC 9535 path error not raised")
+	}
+	if _, err := NewPolicy(PolicyRule{Path: "$.a[-0]", Operator: DeleteOperator{}, RFC9535: true}); err == nil {
+		t.Errorf("RFC 9535 syntax error not raised")
+	}
+	p, _ = NewPolicy(PolicyRule{Path: "$.a.b", Operator: MaskOperator{Func: PhoneDesensitization, NonString: NonStringError}})
+	if _, err := p.Apply(obj); err == nil {
+		t.Errorf("operator error not raised")
+	}
+	//删除根节点
+	p, _ = NewPolicy(PolicyRule{Path: "$", Operator: DeleteOperator{}}, PolicyRule{Path: "$.a", Operator: upperOperator{}})
+	if res, err := p.Apply(obj); err != nil || res != nil {
+		t.Errorf("root not deleted: %v, %v", res, err)
+	}
+}
+
+//规则相同的前缀在树中共用一个节点，编译选项不同时不共用
+func Test_jsonpath_policy_shared_prefix(t *testing.T) {
+	p, err := NewPolicy(
+		PolicyRule{Path: "$.data.items[*].phone", Operator: MaskOperator{Func: PhoneDesensitization}},
+		PolicyRule{Path: "$.data.items[*].name", Operator: MaskOperator{Func: NameDesensitization}},
+		PolicyRule{Path: "$.data.total", Operator: DeleteOperator{}},
+		PolicyRule{Path: "$.data.items[*].id", Operator: DeleteOperator{}, Options: []Option{WithStrictKeys()}},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(p.root.children) != 2 {
+		t.Fatalf("root children: %d", len(p.root.children))
+	}
+	data := p.root.children[0]
+	if len(data.children) != 2 || len(data.children[0].children) != 2 {
+		t.Errorf("prefix not shared: %d, %d", len(data.children), len(data.children[0].children))
+	}
+
+	var obj, expect interface{}
+	json.Unmarshal([]byte(`{"data": {"items": [{"id": 1, "phone": "13800138000", "name": "张三丰"}, {"id": 2, "phone": "13900139000", "name": "李四"}], "total": 2}}`), &obj)
+	json.Unmarshal([]byte(`{"data": {"items": [{"phone": "138****8000", "name": "张*丰"}, {"phone": "139****9000", "name": "李*"}]}}`), &expect)
+	res, err := p.Apply(obj)
+	if err != nil || !reflect.DeepEqual(res, expect) {
+		t.Errorf("(got)%v != (exp)%v, err: %v", toString(res), toString(expect), err)
+	}
+}
+
+//多条'..'规则在一次遍历中查找，结果与逐条调用相同；其中一条规则取不到值时不影响其它规则
+func Test_jsonpath_policy_scan(t *testing.T) {
+	doc := `{"user": {"name": "张三丰", "phone": "13800138000", "email": "a@b.com", "id": 1}, "list": [{"name": "李四", "phone": "13900139000"}, {"contact": {"phone": "13700137000", "email": "c@d.com"}}]}`
+	var obj, expect interface{}
+	json.Unmarshal([]byte(doc), &obj)
+	json.Unmarshal([]byte(doc), &expect)
+	rules := []PolicyRule{
+		{Path: "$..phone", Operator: MaskOperator{Func: PhoneDesensitization}},
+		{Path: "$..email", Operator: DeleteOperator{}},
+		{Path: "$..name[1]", Operator: MaskOperator{Func: NameDesensitization}},
+		{Path: "$..*[?(@.email)].phone", Operator: DeleteOperator{}},
+		{Path: "$..id[5]", Operator: DeleteOperator{}},
+	}
+	JsonPathLookUpAndDel(expect, "$..*[?(@.email)].phone")
+	JsonPathLookUpAndDesensitization(expect, "$..phone", PhoneDesensitization)
+	JsonPathLookUpAndDel(expect, "$..email")
+	JsonPathLookUpAndDesensitization(expect, "$..name[1]", NameDesensitization)
+	p, err := NewPolicy(rules...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(p.root.children) != 5 {
+		t.Errorf("scan steps should be children of root: %d", len(p.root.children))
+	}
+	res, err := p.Apply(obj)
+	if err != nil || !reflect.DeepEqual(res, expect) {
+		t.Errorf("(got)%v != (exp)%v, err: %v", toString(res), toString(expect), err)
+	}
+
+	rules[4].Required = true
+	p, _ = NewPolicy(rules...)
+	json.Unmarshal([]byte(doc), &obj)
+	if _, err := p.Apply(obj); err == nil {
+		t.Errorf("required scan error not raised")
+	}
+}
+
+//40条规则的策略，10条规则对过滤出的数组元素中的字段脱敏，10条规则用'..'查找字段脱敏，20条规则删除字段
+func benchmark_policy_data() (interface{}, []PolicyRule) {
+	items := []interface{}{}
+	for i := 0; i < 20; i++ {
+		item := map[string]interface{}{"status": float64(i % 2)}
+		for k := 0; k < 20; k++ {
+			item[fmt.Sprintf("f%d", k)] = "13800138000"
+		}
+		items = append(items, item)
+	}
+	user := map[string]interface{}{}
+	for k := 0; k < 40; k++ {
+		user[fmt.Sprintf("u%d", k)] = "13800138000"
+	}
+	doc := map[string]interface{}{"data": map[string]interface{}{"items": items, "user": user}}
+	rules := []PolicyRule{}
+	for k := 0; k < 20; k++ {
+		path := fmt.Sprintf("$.data.items[?(@.status == 1)].f%d", k)
+		if k%2 == 1 {
+			path = fmt.Sprintf("$..f%d", k)
+		}
+		rules = append(rules, PolicyRule{Path: path, Operator: MaskOperator{Func: PhoneDesensitization}})
+		rules = append(rules, PolicyRule{Path: fmt.Sprintf("$.data.user.u%d", k), Operator: DeleteOperator{}})
+	}
+	return doc, rules
+}
+
+func BenchmarkPolicyApply(b *testing.B) {
+	doc, rules := benchmark_policy_data()
+	p, err := NewPolicy(rules...)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := p.Apply(deep_copy(doc)); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+//与BenchmarkPolicyApply相同的规则逐条调用JsonPathLookUpAndDel和JsonPathLookUpAndDesensitization
+func BenchmarkPolicySeparateCalls(b *testing.B) {
+	doc, rules := benchmark_policy_data()
+	for i := 0; i < b.N; i++ {
+		obj := deep_copy(doc)
+		for _, rule := range rules {
+			var err error
+			if m, ok := rule.Operator.(MaskOperator); ok {
+				_, err = JsonPathLookUpAndDesensitization(obj, rule.Path, m.Func)
+			} else {
+				_, err = JsonPathLookUpAndDel(obj, rule.Path)
+			}
+			if err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
@@ -0,0 +1,131 @@
+package jsonpath
+
+import (
+	"sort"
+)
+
+//投影时需要保留的位置组成的树
+//all 为true时保留整个值，否则只保留keys和idxs中的子节点
+type projection struct {
+	all  bool
+	keys map[string]*projection
+	idxs map[int]*projection
+}
+
+//向外暴露通过jsonpath只保留指定字段的接口
+func JsonPathProject(obj interface{}, jpaths ...string) (interface{}, error) {
+	paths := make([]*Compiled, 0, len(jpaths))
+	for _, jpath := range jpaths {
+		c, err := Compile(jpath)
+		if err != nil {
+			return nil, err
+		}
+		paths = append(paths, c)
+	}
+	return Project(obj, paths...)
+}
+
+//返回只包含paths匹配到的位置的新数据，与LookupAndOperate删除字段相反
+//匹配位置外层的对象和数组保留原来的结构，对象只保留选中的键值，数组只保留选中的元素并保持原来的顺序
+//匹配到的值整体复制，结果与obj不共享对象和数组，修改结果不会影响obj
+//路径取不到值时跳过，Compile时使用WithStrictKeys()的路径取不到值时返回错误
+//没有匹配到任何位置时返回与obj类型相同的空对象或空数组
+func Project(obj interface{}, paths ...*Compiled) (interface{}, error) {
+	root := &projection{}
+	for _, c := range paths {
+		var nodes []*node
+		if c.query != nil {
+			nodes = evalQuery(c.query, obj, obj)
+		} else {
+			var err error
+			nodes, err = c.lookup_nodes(obj, false)
+			if err != nil {
+				if c.strict {
+					return nil, err
+				}
+				continue
+			}
+		}
+		for _, n := range nodes {
+			root.add(n).all = true
+		}
+	}
+	return root.project(obj), nil
+}
+
+//把节点n所在的位置加入树中，返回对应的树节点
+func (p *projection) add(n *node) *projection {
+	if n.parent == nil {
+		return p
+	}
+	parent := p.add(n.parent)
+	switch key := n.key.(type) {
+	case string:
+		if parent.keys == nil {
+			parent.keys = make(map[string]*projection)
+		}
+		if parent.keys[key] == nil {
+			parent.keys[key] = &projection{}
+		}
+		return parent.keys[key]
+	case int:
+		if parent.idxs == nil {
+			parent.idxs = make(map[int]*projection)
+		}
+		if parent.idxs[key] == nil {
+			parent.idxs[key] = &projection{}
+		}
+		return parent.idxs[key]
+	}
+	return parent
+}
+
+//按树中的位置复制value
+func (p *projection) project(value interface{}) interface{} {
+	if p.all {
+		return deep_copy(value)
+	}
+	switch v := value.(type) {
+	case map[string]interface{}:
+		res := make(map[string]interface{}, len(p.keys))
+		for key, child := range p.keys {
+			if cv, ok := v[key]; ok {
+				res[key] = child.project(cv)
+			}
+		}
+		return res
+	case []interface{}:
+		idxs := make([]int, 0, len(p.idxs))
+		for idx := range p.idxs {
+			if idx >= 0 && idx < len(v) {
+				idxs = append(idxs, idx)
+			}
+		}
+		sort.Ints(idxs)
+		res := make([]interface{}, 0, len(idxs))
+		for _, idx := range idxs {
+			res = append(res, p.idxs[idx].project(v[idx]))
+		}
+		return res
+	}
+	return value
+}
+
+//复制json中的对象和数组
+func deep_copy(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		res := make(map[string]interface{}, len(v))
+		for key, cv := range v {
+			res[key] = deep_copy(cv)
+		}
+		return res
+	case []interface{}:
+		res := make([]interface{}, len(v))
+		for i, cv := range v {
+			res[i] = deep_copy(cv)
+		}
+		return res
+	}
+	return value
+}
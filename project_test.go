@@ -0,0 +1,73 @@
+package jsonpath
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func Test_jsonpath_project(t *testing.T) {
+	doc := `{"user": {"name": "a", "phone": "13800138000", "addr": {"city": "bj", "street": "s1"}}, "orders": [{"id": 1, "price": 10, "items": ["x", "y"]}, {"id": 2, "price": 20, "items": ["z"]}], "total": 30}`
+	tcases := []struct {
+		Paths  []string
+		Expect string
+	}{
+		{[]string{"$.user.name"}, `{"user": {"name": "a"}}`},
+		{[]string{"$.user.name", "$.total"}, `{"user": {"name": "a"}, "total": 30}`},
+		{[]string{"$.user.addr", "$.user.addr.city"}, `{"user": {"addr": {"city": "bj", "street": "s1"}}}`},
+		{[]string{"$.user.*"}, `{"user": {"name": "a", "phone": "13800138000", "addr": {"city": "bj", "street": "s1"}}}`},
+		{[]string{"$.orders.id"}, `{"orders": [{"id": 1}, {"id": 2}]}`},
+		{[]string{"$.orders[1].price", "$.orders[0].id"}, `{"orders": [{"id": 1}, {"price": 20}]}`},
+		{[]string{"$.orders[-1].items[0]"}, `{"orders": [{"items": ["z"]}]}`},
+		{[]string{"$.orders[?(@.price > 10)].id"}, `{"orders": [{"id": 2}]}`},
+		{[]string{"$..city", "$..id"}, `{"user": {"addr": {"city": "bj"}}, "orders": [{"id": 1}, {"id": 2}]}`},
+		{[]string{"$.user.email", "$.total"}, `{"total": 30}`},
+		{[]string{"$.user.email"}, `{}`},
+		{[]string{"$"}, doc},
+		{nil, `{}`},
+	}
+	for idx, tcase := range tcases {
+		var obj, expect interface{}
+		json.Unmarshal([]byte(doc), &obj)
+		json.Unmarshal([]byte(tcase.Expect), &expect)
+		res, err := JsonPathProject(obj, tcase.Paths...)
+		if err != nil || !reflect.DeepEqual(res, expect) {
+			t.Errorf("idx: %d, paths: %v, (got)%v != (exp)%v, err: %v", idx, tcase.Paths, toString(res), tcase.Expect, err)
+		}
+	}
+}
+
+func Test_jsonpath_project_copy(t *testing.T) {
+	var obj, origin interface{}
+	doc := `{"user": {"name": "a", "tags": ["x"]}, "list": [{"n": 1}, {"n": 2}]}`
+	json.Unmarshal([]byte(doc), &obj)
+	json.Unmarshal([]byte(doc), &origin)
+	c1, _ := Compile("$.user")
+	c2, _ := CompileRFC9535("$.list[?@.n > 1]")
+	res, err := Project(obj, c1, c2)
+	var expect interface{}
+	json.Unmarshal([]byte(`{"user": {"name": "a", "tags": ["x"]}, "list": [{"n": 2}]}`), &expect)
+	if err != nil || !reflect.DeepEqual(res, expect) {
+		t.Fatalf("(got)%v != (exp)%v, err: %v", toString(res), toString(expect), err)
+	}
+	//修改结果不影响原来的数据
+	user := res.(map[string]interface{})["user"].(map[string]interface{})
+	user["name"] = "b"
+	user["tags"].([]interface{})[0] = "y"
+	res.(map[string]interface{})["list"].([]interface{})[0].(map[string]interface{})["n"] = 3
+	if !reflect.DeepEqual(obj, origin) {
+		t.Errorf("origin modified: %v", toString(obj))
+	}
+}
+
+func Test_jsonpath_project_error(t *testing.T) {
+	var obj interface{}
+	json.Unmarshal([]byte(`{"a": {"b": 1}}`), &obj)
+	if _, err := JsonPathProject(obj, "$.a", "$.a.]"); err == nil {
+		t.Errorf("syntax error not raised")
+	}
+	c, _ := Compile("$.a.c", WithStrictKeys())
+	if _, err := Project(obj, c); err == nil {
+		t.Errorf("strict key error not raised")
+	}
+}
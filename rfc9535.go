@@ -0,0 +1,248 @@
+package jsonpath
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+//RFC 9535模式下查询结果中的单个节点
+//value 节点的值
+//key 在父节点中的键值(string)或下标(int)，根节点为nil
+//parent 父节点，根节点为nil
+type node struct {
+	value  interface{}
+	key    interface{}
+	parent *node
+}
+
+//按RFC 9535(https://www.rfc-editor.org/rfc/rfc9535)的语法和语义解析jsonpath
+//目前只用testdata/cts_subset.json中的部分用例验证过，没有跑过上游完整的compliance test suite
+//与Compile的区别:
+//  Lookup总是返回节点列表([]interface{})，没有匹配时为空列表而不是错误
+//  '[start:end:step]'不包含end，支持step
+//  过滤表达式支持 && || ! 和括号，以及 length() count() match() search() value() 函数
+//  比较时不做类型转换，不同类型的值不相等
+//LookupAndOperate和LookupAndApply处理的节点与LookupNodes相同
+//opts中只有WithNonStringMask有作用，其它选项只影响Compile模式
+func CompileRFC9535(jpath string, opts ...Option) (*Compiled, error) {
+	node, err := parsePathRFC9535(jpath)
+	if err != nil {
+		return nil, err
+	}
+	c := &Compiled{
+		path:  jpath,
+		query: node,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c, nil
+}
+
+func MustCompileRFC9535(jpath string, opts ...Option) *Compiled {
+	c, err := CompileRFC9535(jpath, opts...)
+	if err != nil {
+		panic(err)
+	}
+	return c
+}
+
+//RFC 9535模式的查询，返回所有匹配节点的值
+func (c *Compiled) lookupRFC9535(obj interface{}) []interface{} {
+	nodes := evalQuery(c.query, obj, obj)
+	res := make([]interface{}, len(nodes))
+	for i, n := range nodes {
+		res[i] = n.value
+	}
+	return res
+}
+
+//执行查询，current为'@'对应的值，root为'$'对应的值
+func evalQuery(q *pathNode, current, root interface{}) []*node {
+	start := root
+	if q.root == "@" {
+		start = current
+	}
+	nodes := []*node{{value: start}}
+	for _, seg := range q.segments {
+		res := []*node{}
+		for _, n := range nodes {
+			if seg.descendant {
+				for _, d := range descendants(n, nil) {
+					res = append(res, selectChildren(seg.selectors, d, root)...)
+				}
+			} else {
+				res = append(res, selectChildren(seg.selectors, n, root)...)
+			}
+		}
+		nodes = res
+	}
+	return nodes
+}
+
+//按文档顺序返回节点自身和它的所有后代节点，对象的成员按键值排序
+func descendants(n *node, res []*node) []*node {
+	res = append(res, n)
+	for _, child := range children(n) {
+		res = descendants(child, res)
+	}
+	return res
+}
+
+//节点的所有直接子节点
+func children(n *node) []*node {
+	if keys, ok := objectKeys(n.value); ok {
+		res := make([]*node, 0, len(keys))
+		for _, k := range keys {
+			v, _ := objectGet(n.value, k)
+			res = append(res, &node{value: v, key: k, parent: n})
+		}
+		return res
+	}
+	if length, ok := arrayLen(n.value); ok {
+		res := make([]*node, 0, length)
+		for i := 0; i < length; i++ {
+			res = append(res, &node{value: arrayGet(n.value, i), key: i, parent: n})
+		}
+		return res
+	}
+	return nil
+}
+
+//依次执行段中的选择器，结果按选择器的顺序拼接
+func selectChildren(sels []selector, n *node, root interface{}) []*node {
+	res := []*node{}
+	for _, sel := range sels {
+		switch sel.kind {
+		case "name":
+			if v, ok := objectGet(n.value, sel.name); ok {
+				res = append(res, &node{value: v, key: sel.name, parent: n})
+			}
+		case "wildcard":
+			res = append(res, children(n)...)
+		case "index":
+			length, ok := arrayLen(n.value)
+			if !ok {
+				continue
+			}
+			idx := sel.index
+			if idx < 0 {
+				idx += length
+			}
+			if idx >= 0 && idx < length {
+				res = append(res, &node{value: arrayGet(n.value, idx), key: idx, parent: n})
+			}
+		case "slice":
+			length, ok := arrayLen(n.value)
+			if !ok {
+				continue
+			}
+			for _, idx := range sliceIndices(length, sel.slice[0], sel.slice[1], sel.slice[2]) {
+				res = append(res, &node{value: arrayGet(n.value, idx), key: idx, parent: n})
+			}
+		case "filter":
+			for _, child := range children(n) {
+				if evalLogical(sel.expr, child.value, root) {
+					res = append(res, child)
+				}
+			}
+		}
+	}
+	return res
+}
+
+//按RFC 9535中切片的规则计算选中的下标，start、end、step为nil时使用默认值
+//step为0时不选中任何元素，step为负数时倒序选择
+func sliceIndices(length int, start, end, step interface{}) []int {
+	st := 1
+	if step != nil {
+		st = step.(int)
+	}
+	if st == 0 {
+		return nil
+	}
+	normalize := func(i interface{}, def int) int {
+		if i == nil {
+			return def
+		}
+		v := i.(int)
+		if v < 0 {
+			return length + v
+		}
+		return v
+	}
+	clamp := func(i, lower, upper int) int {
+		if i < lower {
+			return lower
+		}
+		if i > upper {
+			return upper
+		}
+		return i
+	}
+	res := []int{}
+	if st > 0 {
+		lower := clamp(normalize(start, 0), 0, length)
+		upper := clamp(normalize(end, length), 0, length)
+		for i := lower; i < upper; i += st {
+			res = append(res, i)
+		}
+	} else {
+		upper := clamp(normalize(start, length-1), -1, length-1)
+		lower := clamp(normalize(end, -length-1), -1, length-1)
+		for i := upper; lower < i; i += st {
+			res = append(res, i)
+		}
+	}
+	return res
+}
+
+//节点的规范化路径(normalized path)，例如 $['store']['book'][0]
+func (n *node) path() string {
+	keys := []interface{}{}
+	for cur := n; cur.parent != nil; cur = cur.parent {
+		keys = append(keys, cur.key)
+	}
+	var sb strings.Builder
+	sb.WriteString("$")
+	for i := len(keys) - 1; i >= 0; i-- {
+		switch k := keys[i].(type) {
+		case int:
+			sb.WriteString("[" + strconv.Itoa(k) + "]")
+		case string:
+			sb.WriteString("['" + escapeName(k) + "']")
+		}
+	}
+	return sb.String()
+}
+
+//规范化路径中键值的转义规则
+func escapeName(name string) string {
+	var sb strings.Builder
+	for _, r := range name {
+		switch r {
+		case '\b':
+			sb.WriteString(`\b`)
+		case '\f':
+			sb.WriteString(`\f`)
+		case '\n':
+			sb.WriteString(`\n`)
+		case '\r':
+			sb.WriteString(`\r`)
+		case '\t':
+			sb.WriteString(`\t`)
+		case '\'':
+			sb.WriteString(`\'`)
+		case '\\':
+			sb.WriteString(`\\`)
+		default:
+			if r < 0x20 {
+				sb.WriteString(fmt.Sprintf(`\u%04x`, r))
+			} else {
+				sb.WriteRune(r)
+			}
+		}
+	}
+	return sb.String()
+}
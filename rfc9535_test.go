@@ -0,0 +1,194 @@
+package jsonpath
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+var rfc_cases = []struct {
+	Query  string
+	Doc    string
+	Expect string
+}{
+	{`$`, `{"a":1}`, `[{"a":1}]`},
+	{`$.a`, `{"a":1}`, `[1]`},
+	{`$.missing`, `{"a":1}`, `[]`},
+	{`$["a"]`, `{"a":1}`, `[1]`},
+	{`$['a','a']`, `{"a":1}`, `[1,1]`},
+	{`$[0]`, `[1,2,3]`, `[1]`},
+	{`$[-1]`, `[1,2,3]`, `[3]`},
+	{`$[5]`, `[1,2,3]`, `[]`},
+	{`$.*`, `{"b":2,"a":1}`, `[1,2]`},
+	{`$[*]`, `[1,2]`, `[1,2]`},
+	{`$[1:3]`, `[0,1,2,3,4]`, `[1,2]`},
+	{`$[::2]`, `[0,1,2,3,4]`, `[0,2,4]`},
+	{`$[::-1]`, `[0,1,2,3]`, `[3,2,1,0]`},
+	{`$[-2:]`, `[0,1,2,3]`, `[2,3]`},
+	{`$[1:0]`, `[0,1,2,3]`, `[]`},
+	{`$[5:1:-2]`, `[0,1,2,3,4,5,6]`, `[5,3]`},
+	{`$[::0]`, `[0,1,2]`, `[]`},
+	{`$[-100:100]`, `[0,1]`, `[0,1]`},
+	{`$..a`, `{"a":1,"b":{"a":2,"c":[{"a":3}]}}`, `[1,2,3]`},
+	{`$..*`, `{"a":[1],"b":2}`, `[[1],2,1]`},
+	{`$..[0]`, `[[1,2],[3]]`, `[[1,2],1,3]`},
+	{`$[0, 0]`, `[7]`, `[7,7]`},
+	{`$[?@.a]`, `[{"a":null},{"b":1}]`, `[{"a":null}]`},
+	{`$[?@.a == null]`, `[{"a":null},{"b":1}]`, `[{"a":null}]`},
+	{`$[?@.a == 1]`, `[{"a":1},{"a":"1"},{"a":1.0}]`, `[{"a":1},{"a":1}]`},
+	{`$[?@.a < 2]`, `[{"a":1},{"a":"1"},{"a":3}]`, `[{"a":1}]`},
+	{`$[?@.a <= 'b']`, `[{"a":"a"},{"a":"b"},{"a":"c"},{"a":1}]`, `[{"a":"a"},{"a":"b"}]`},
+	{`$[?@.a && @.b]`, `[{"a":1},{"a":1,"b":2}]`, `[{"a":1,"b":2}]`},
+	{`$[?@.a || @.b]`, `[{"a":1},{"b":2},{"c":3}]`, `[{"a":1},{"b":2}]`},
+	{`$[?!@.a]`, `[{"a":1},{"b":2}]`, `[{"b":2}]`},
+	{`$[?!(@.a == 1 || @.a == 2)]`, `[{"a":1},{"a":2},{"a":3}]`, `[{"a":3}]`},
+	{`$[?@.a == 1 || @.a == 2 && @.b]`, `[{"a":1},{"a":2},{"a":2,"b":0}]`, `[{"a":1},{"a":2,"b":0}]`},
+	{`$[?(@.a == 1)]`, `[{"a":1},{"a":2}]`, `[{"a":1}]`},
+	{`$[?@.a == $.x]`, `{"x":1,"y":{"a":1}}`, `[{"a":1}]`},
+	{`$.y[?@ == $.x]`, `{"x":1,"y":[1,2]}`, `[1]`},
+	{`$[?@.a == @.b]`, `[{"c":1}]`, `[{"c":1}]`},
+	{`$[?@.a == $.b]`, `{"a":[1,{"x":2}],"b":[1,{"x":2}]}`, `[]`},
+	{`$[?@ == $.b]`, `{"a":[1,{"x":2}],"b":[1,{"x":2}]}`, `[[1,{"x":2}],[1,{"x":2}]]`},
+	{`$[?@.*]`, `[[],[1],{},{"a":1}]`, `[[1],{"a":1}]`},
+	{`$[?length(@) == 2]`, `["ab","abc",[1,2],{"a":1,"b":2},2]`, `["ab",[1,2],{"a":1,"b":2}]`},
+	{`$[?length(@.a) > 1]`, `[{"a":"用户"},{"a":"x"}]`, `[{"a":"用户"}]`},
+	{`$[?count(@.*) == 1]`, `[[1],[1,2],{"a":1}]`, `[[1],{"a":1}]`},
+	{`$[?match(@, 'a.c')]`, `["abc","abcd","a\nc"]`, `["abc"]`},
+	{`$[?search(@, '[bc]d')]`, `["abc","abcd","bd"]`, `["abcd","bd"]`},
+	{`$[?match(@, '\\d')]`, `["1"]`, `[]`},
+	{`$[?search(@, '^a')]`, `["abc","x^a"]`, `["x^a"]`},
+	{`$[?value(@..a) == 1]`, `[{"a":1},{"b":{"a":1}},{"a":1,"b":{"a":1}}]`, `[{"a":1},{"b":{"a":1}}]`},
+	{`$[?@.a != 1]`, `[{"a":1},{"b":1}]`, `[{"b":1}]`},
+	{`$[?@.a > 1e0]`, `[{"a":1},{"a":1.5}]`, `[{"a":1.5}]`},
+	{`$['\'']`, `{"'":1}`, `[1]`},
+}
+
+var rfc_error_cases = []string{
+	``,
+	` $`,
+	`$ `,
+	`@.a`,
+	`$. a`,
+	`$.1a`,
+	`$.a-b`,
+	`$.[0]`,
+	`$....a`,
+	`$[01]`,
+	`$[-0]`,
+	`$[9007199254740992]`,
+	`$[1.0]`,
+	`$["\'"]`,
+	`$[?@.a == 01]`,
+	`$[?1]`,
+	`$[?@.* == 1]`,
+	`$[?@..a == 1]`,
+	`$[?length(@)]`,
+	`$[?length(@.*) == 1]`,
+	`$[?count(1) == 1]`,
+	`$[?match(@, 'a') == true]`,
+	`$[?foo(@)]`,
+	`$[?length (@) == 1]`,
+	`$[?!@.a == 1]`,
+	`$[?@.a =~ /a/]`,
+	`$[?@.a == [1,2]]`,
+	`$[?(@.a]`,
+}
+
+func Test_jsonpath_rfc9535_lookup(t *testing.T) {
+	for idx, tcase := range rfc_cases {
+		c, err := CompileRFC9535(tcase.Query)
+		if err != nil {
+			t.Errorf("idx: %d, query: %s, compile error: %v", idx, tcase.Query, err)
+			continue
+		}
+		var doc, expect interface{}
+		json.Unmarshal([]byte(tcase.Doc), &doc)
+		json.Unmarshal([]byte(tcase.Expect), &expect)
+		res, err := c.Lookup(doc)
+		if err != nil {
+			t.Errorf("idx: %d, query: %s, lookup error: %v", idx, tcase.Query, err)
+			continue
+		}
+		if !valueEqual(res, expect) {
+			t.Errorf("idx: %d, query: %s, (got)%v != (exp)%v", idx, tcase.Query, res, expect)
+		}
+	}
+}
+
+func Test_jsonpath_rfc9535_syntax_error(t *testing.T) {
+	for idx, query := range rfc_error_cases {
+		_, err := CompileRFC9535(query)
+		if _, ok := err.(*SyntaxError); ok != true {
+			t.Errorf("idx: %d, query: %q, should return *SyntaxError, got: %v", idx, query, err)
+		}
+	}
+}
+
+func Test_jsonpath_rfc9535_normalized_path(t *testing.T) {
+	var doc interface{}
+	json.Unmarshal([]byte(`{"a":[{"b":1},{"b":2}],"it's":{"\n\u0001":3}}`), &doc)
+	tcases := []struct {
+		Query string
+		Paths []string
+	}{
+		{`$`, []string{`$`}},
+		{`$.a[*].b`, []string{`$['a'][0]['b']`, `$['a'][1]['b']`}},
+		{`$.a[-1]`, []string{`$['a'][1]`}},
+		{`$..b`, []string{`$['a'][0]['b']`, `$['a'][1]['b']`}},
+		{`$["it's"].*`, []string{`$['it\'s']['\n\u0001']`}},
+	}
+	for idx, tcase := range tcases {
+		nodes := evalQuery(MustCompileRFC9535(tcase.Query).query, doc, doc)
+		paths := []string{}
+		for _, n := range nodes {
+			paths = append(paths, n.path())
+		}
+		if !reflect.DeepEqual(paths, tcase.Paths) {
+			t.Errorf("idx: %d, query: %s, (got)%v != (exp)%v", idx, tcase.Query, paths, tcase.Paths)
+		}
+	}
+}
+
+func Test_jsonpath_slice_indices(t *testing.T) {
+	tcases := []struct {
+		Len              int
+		Start, End, Step interface{}
+		Expect           []int
+	}{
+		{5, nil, nil, nil, []int{0, 1, 2, 3, 4}},
+		{5, 1, -1, nil, []int{1, 2, 3}},
+		{5, nil, nil, -2, []int{4, 2, 0}},
+		{5, -1, -4, -1, []int{4, 3, 2}},
+		{5, nil, nil, 0, nil},
+		{0, nil, nil, -1, []int{}},
+	}
+	for idx, tcase := range tcases {
+		res := sliceIndices(tcase.Len, tcase.Start, tcase.End, tcase.Step)
+		if !reflect.DeepEqual(res, tcase.Expect) {
+			t.Errorf("idx: %d, (got)%v != (exp)%v", idx, res, tcase.Expect)
+		}
+	}
+}
+
+func Test_jsonpath_iregexp(t *testing.T) {
+	tcases := []struct {
+		Pattern string
+		Expect  string
+		Valid   bool
+	}{
+		{`a.c`, `a[^\n\r]c`, true},
+		{`^a$`, `\^a\$`, true},
+		{`[a-z]+`, `[a-z]+`, true},
+		{`\p{Lu}`, `\p{Lu}`, true},
+		{`\.`, `\.`, true},
+		{`\d`, ``, false},
+		{`(?i)a`, ``, false},
+		{`[a`, ``, false},
+	}
+	for idx, tcase := range tcases {
+		res, ok := iregexpToGo(tcase.Pattern)
+		if ok != tcase.Valid || res != tcase.Expect {
+			t.Errorf("idx: %d, pattern: %s, (got)%q %v != (exp)%q %v", idx, tcase.Pattern, res, ok, tcase.Expect, tcase.Valid)
+		}
+	}
+}
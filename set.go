@@ -0,0 +1,44 @@
+package jsonpath
+
+//把所有匹配位置的值设置为value，返回修改后的obj
+//支持key、idx、range、wildcard、union、filter和scan，路径为$时返回value
+//Compile时使用WithCreateMissing()可以创建缺少的中间对象和数组
+func (c *Compiled) Set(obj interface{}, value interface{}) (interface{}, error) {
+	return c.Update(obj, func(old interface{}) interface{} {
+		return value
+	})
+}
+
+//用fn的返回值替换所有匹配位置的值，返回修改后的obj
+//fn的参数为原来的值，新创建的位置为nil
+//嵌套的匹配项先于外层的匹配项处理，外层的fn能看到内层修改后的值；同一个位置只处理一次
+func (c *Compiled) Update(obj interface{}, fn func(old interface{}) interface{}) (interface{}, error) {
+	var nodes []*node
+	if c.query != nil {
+		nodes = evalQuery(c.query, obj, obj)
+	} else {
+		var err error
+		nodes, err = c.lookup_nodes(obj, c.create)
+		if err != nil {
+			return nil, err
+		}
+	}
+	//同一个位置被选中多次时只处理一次
+	seen := make(map[string]bool)
+	for i := len(nodes) - 1; i >= 0; i-- {
+		n := nodes[i]
+		path := n.path()
+		if seen[path] {
+			continue
+		}
+		seen[path] = true
+		if n.parent == nil {
+			obj = fn(n.value)
+			continue
+		}
+		if err := set_node(n, fn(n.value)); err != nil {
+			return nil, err
+		}
+	}
+	return obj, nil
+}
@@ -0,0 +1,132 @@
+package jsonpath
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func Test_jsonpath_set(t *testing.T) {
+	doc := `{"a": {"b": 1}, "list": [{"x": 1}, {"x": 2, "y": 3}, {"x": 3}], "n": null}`
+	tcases := []struct {
+		Path   string
+		Create bool
+		Expect string
+	}{
+		{"$.a.b", false, `{"a":{"b":0},"list":[{"x":1},{"x":2,"y":3},{"x":3}],"n":null}`},
+		{"$.list[1]", false, `{"a":{"b":1},"list":[{"x":1},0,{"x":3}],"n":null}`},
+		{"$.list[-1].x", false, `{"a":{"b":1},"list":[{"x":1},{"x":2,"y":3},{"x":0}],"n":null}`},
+		{"$.list[0:1].x", false, `{"a":{"b":1},"list":[{"x":0},{"x":0,"y":3},{"x":3}],"n":null}`},
+		{"$.list[?(@.x > 1)].x", false, `{"a":{"b":1},"list":[{"x":1},{"x":0,"y":3},{"x":0}],"n":null}`},
+		{"$..x", false, `{"a":{"b":1},"list":[{"x":0},{"x":0,"y":3},{"x":0}],"n":null}`},
+		{"$..x[1]", false, `{"a":{"b":1},"list":[{"x":1},{"x":0,"y":3},{"x":3}],"n":null}`},
+		{"$.list.y", false, `{"a":{"b":1},"list":[{"x":1},{"x":2,"y":0},{"x":3}],"n":null}`},
+		{"$.a.*", false, `{"a":{"b":0},"list":[{"x":1},{"x":2,"y":3},{"x":3}],"n":null}`},
+		{"$.list[0,0].x", false, `{"a":{"b":1},"list":[{"x":0},{"x":2,"y":3},{"x":3}],"n":null}`},
+		{"$.a.c.d", true, `{"a":{"b":1,"c":{"d":0}},"list":[{"x":1},{"x":2,"y":3},{"x":3}],"n":null}`},
+		{"$.a.e[2]", true, `{"a":{"b":1,"e":[null,null,0]},"list":[{"x":1},{"x":2,"y":3},{"x":3}],"n":null}`},
+		{"$.list[4].x", true, `{"a":{"b":1},"list":[{"x":1},{"x":2,"y":3},{"x":3},null,{"x":0}],"n":null}`},
+		{"$.list[*].y", true, `{"a":{"b":1},"list":[{"x":1,"y":0},{"x":2,"y":0},{"x":3,"y":0}],"n":null}`},
+		{"$.n.m", true, `{"a":{"b":1},"list":[{"x":1},{"x":2,"y":3},{"x":3}],"n":{"m":0}}`},
+		{"$.x[1].y", true, `{"a":{"b":1},"list":[{"x":1},{"x":2,"y":3},{"x":3}],"n":null,"x":[null,{"y":0}]}`},
+		{"$", false, `0`},
+	}
+	for idx, tcase := range tcases {
+		var obj, expect interface{}
+		json.Unmarshal([]byte(doc), &obj)
+		json.Unmarshal([]byte(tcase.Expect), &expect)
+		var opts []Option
+		if tcase.Create {
+			opts = append(opts, WithCreateMissing())
+		}
+		res, err := MustCompile(tcase.Path, opts...).Set(obj, 0.0)
+		if err != nil || !reflect.DeepEqual(res, expect) {
+			t.Errorf("idx: %d, path: %s, (got)%v != (exp)%v, err: %v", idx, tcase.Path, toString(res), tcase.Expect, err)
+		}
+	}
+}
+
+func Test_jsonpath_set_error(t *testing.T) {
+	doc := `{"a": {"b": 1}, "list": [1, 2]}`
+	paths := []string{
+		"$.a.c.d",
+		"$.list[5]",
+		"$.a.b.c",
+	}
+	for idx, path := range paths {
+		var obj interface{}
+		json.Unmarshal([]byte(doc), &obj)
+		if _, err := MustCompile(path).Set(obj, 0.0); err == nil {
+			t.Errorf("idx: %d, path: %s, error not raised", idx, path)
+		}
+	}
+	var obj interface{}
+	json.Unmarshal([]byte(doc), &obj)
+	if _, err := MustCompile("$.a.b.c", WithCreateMissing()).Set(obj, 0.0); err == nil {
+		t.Errorf("should not create key in number")
+	}
+}
+
+//WithCreateMissing不会为range和wildcard创建元素，返回错误并且数据不被修改
+func Test_jsonpath_set_create_range(t *testing.T) {
+	doc := `{"a": {"b": 1}, "list": [{"x": 1}], "n": null}`
+	paths := []string{
+		"$.x[0:2]",
+		"$.x.y[0:2]",
+		"$.a.c[1:]",
+		"$.n[0:1].m",
+		"$.x.y[*]",
+		"$.x.y.*",
+		"$.x[1].y[0:2]",
+	}
+	for idx, path := range paths {
+		var obj, expect interface{}
+		json.Unmarshal([]byte(doc), &obj)
+		json.Unmarshal([]byte(doc), &expect)
+		if _, err := MustCompile(path, WithCreateMissing()).Set(obj, 0.0); err == nil {
+			t.Errorf("idx: %d, path: %s, error not raised", idx, path)
+		}
+		if !reflect.DeepEqual(obj, expect) {
+			t.Errorf("idx: %d, path: %s, data modified: %v", idx, path, toString(obj))
+		}
+	}
+}
+
+func Test_jsonpath_update(t *testing.T) {
+	var obj interface{}
+	json.Unmarshal([]byte(`{"items": [{"price": 1, "sub": {"price": 2}}, {"price": 3}], "total": null}`), &obj)
+	_, err := MustCompile("$..price").Update(obj, func(old interface{}) interface{} {
+		return old.(float64) * 10
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, _ := MustCompile("$..price", WithNodeList()).Lookup(obj)
+	if !reflect.DeepEqual(res, []interface{}{10.0, 20.0, 30.0}) {
+		t.Errorf("prices should be multiplied, got: %v", res)
+	}
+
+	//外层的fn能看到内层修改后的值
+	_, err = MustCompileRFC9535("$..sub").Update(obj, func(old interface{}) interface{} {
+		return old.(map[string]interface{})["price"]
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res, _ := JsonPathLookUp(obj, "$.items[0].sub"); res != 20.0 {
+		t.Errorf("sub should be replaced, got: %v", res)
+	}
+
+	//缺少的值作为nil传入
+	var olds []interface{}
+	_, err = MustCompile("$.items[*].count", WithCreateMissing()).Update(obj, func(old interface{}) interface{} {
+		olds = append(olds, old)
+		return 1.0
+	})
+	if err != nil || !reflect.DeepEqual(olds, []interface{}{nil, nil}) {
+		t.Errorf("missing values should be nil, got: %v, err: %v", olds, err)
+	}
+	if res, _ := JsonPathLookUp(obj, "$.items[*].count"); !reflect.DeepEqual(res, []interface{}{1.0, 1.0}) {
+		t.Errorf("count should be created, got: %v", res)
+	}
+}
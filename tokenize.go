@@ -0,0 +1,202 @@
+package jsonpath
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"math/big"
+)
+
+//Feistel网络的轮数，与FF1相同
+const fpeRounds = 10
+
+//保持格式的字符类别，同一类别的字符只会被替换成同类别的字符
+//其它字符(标点、空格等)保持不变
+var fpeClasses = []struct {
+	name  byte
+	first rune
+	radix int
+}{
+	{'9', '0', 10},
+	{'a', 'a', 26},
+	{'A', 'A', 26},
+	//常用汉字
+	{'H', 0x4E00, 0x9FA5 - 0x4E00 + 1},
+}
+
+//把匹配到的值替换成保持格式的token，例如手机号13800138000替换成另一个11位数字
+//相同的Key和Tweak下同一个值总是得到同一个token，可以用token关联不同的数据；使用DetokenizeOperator还原
+//数字、字母和常用汉字分别在各自的字符集内加密，其它字符保持不变
+//加密方式参考FF1:以HMAC-SHA256作为轮函数的10轮Feistel网络，同类字符太少(例如只有2位数字)时token容易被猜到
+type TokenizeOperator struct {
+	Key   []byte
+	Tweak string
+	//值不是字符串时的处理方式，默认把数字转换成字符串后加密，token为字符串；NonStringSkip时保持原值
+	NonString NonStringMode
+}
+
+//还原TokenizeOperator生成的token，Key和Tweak需要与加密时相同
+//由数字生成的token还原后为数字的字符串形式，例如13800138000还原为"13800138000"
+type DetokenizeOperator struct {
+	Key       []byte
+	Tweak     string
+	NonString NonStringMode
+}
+
+//向外暴露通过jsonpath把数据替换成token的接口，key为加密使用的密钥，数字转换成字符串后加密
+func JsonPathLookUpAndTokenize(obj interface{}, jpath string, key []byte) (interface{}, error) {
+	return JsonPathLookUpAndApply(obj, jpath, TokenizeOperator{Key: key})
+}
+
+//向外暴露通过jsonpath把token还原的接口
+func JsonPathLookUpAndDetokenize(obj interface{}, jpath string, key []byte) (interface{}, error) {
+	return JsonPathLookUpAndApply(obj, jpath, DetokenizeOperator{Key: key})
+}
+
+func (o TokenizeOperator) Apply(parent interface{}, key interface{}, value interface{}) (interface{}, bool, error) {
+	return fpe_apply(o.Key, o.Tweak, o.NonString, key, value, true)
+}
+
+func (o DetokenizeOperator) Apply(parent interface{}, key interface{}, value interface{}) (interface{}, bool, error) {
+	return fpe_apply(o.Key, o.Tweak, o.NonString, key, value, false)
+}
+
+func fpe_apply(secret []byte, tweak string, nonString NonStringMode, key interface{}, value interface{}, encrypt bool) (interface{}, bool, error) {
+	if len(secret) == 0 {
+		return nil, false, fmt.Errorf("tokenize key is empty")
+	}
+	str, ok, err := string_operand(nonstring_mode(nonString, NonStringStringify), key, value)
+	if !ok || err != nil {
+		return value, false, err
+	}
+	return fpe_transform(secret, tweak, str, encrypt), false, nil
+}
+
+//对value中每一类字符分别加密或解密，字符的类别和其它字符组成的格式作为tweak的一部分
+func fpe_transform(secret []byte, tweak string, value string, encrypt bool) string {
+	runes := []rune(value)
+	format := make([]byte, 0, len(runes))
+	classes := make([]int, len(runes))
+	for i, c := range runes {
+		classes[i] = -1
+		for j, class := range fpeClasses {
+			if c >= class.first && c < class.first+rune(class.radix) {
+				classes[i] = j
+				break
+			}
+		}
+		if classes[i] >= 0 {
+			format = append(format, fpeClasses[classes[i]].name)
+		} else {
+			format = append(format, string(c)...)
+		}
+	}
+	for j, class := range fpeClasses {
+		var x []int
+		for i, c := range runes {
+			if classes[i] == j {
+				x = append(x, int(c-class.first))
+			}
+		}
+		if len(x) == 0 {
+			continue
+		}
+		f := &fpeCipher{secret: secret, tweak: append([]byte(tweak+"\x00"), format...), radix: class.radix}
+		if encrypt {
+			x = f.encrypt(x)
+		} else {
+			x = f.decrypt(x)
+		}
+		k := 0
+		for i := range runes {
+			if classes[i] == j {
+				runes[i] = class.first + rune(x[k])
+				k++
+			}
+		}
+	}
+	return string(runes)
+}
+
+//单一进制的Feistel网络，x为每一位都小于radix的数字串
+type fpeCipher struct {
+	secret []byte
+	tweak  []byte
+	radix  int
+}
+
+func (f *fpeCipher) encrypt(x []int) []int {
+	u := len(x) / 2
+	a, b := append([]int{}, x[:u]...), append([]int{}, x[u:]...)
+	for i := 0; i < fpeRounds; i++ {
+		m := len(a)
+		c := new(big.Int).Add(f.num(a), f.round(i, len(x), m, b))
+		c.Mod(c, f.modulus(m))
+		a, b = b, f.str(c, m)
+	}
+	return append(a, b...)
+}
+
+func (f *fpeCipher) decrypt(x []int) []int {
+	//每一轮交换a和b，轮数为偶数时加密结果的前u位为a
+	u := len(x) / 2
+	if fpeRounds%2 == 1 {
+		u = len(x) - u
+	}
+	a, b := append([]int{}, x[:u]...), append([]int{}, x[u:]...)
+	for i := fpeRounds - 1; i >= 0; i-- {
+		m := len(b)
+		c := new(big.Int).Sub(f.num(b), f.round(i, len(x), m, a))
+		c.Mod(c, f.modulus(m))
+		a, b = f.str(c, m), a
+	}
+	return append(a, b...)
+}
+
+//第i轮的轮函数，n为数字串的总长度，结果用于m位的模加
+//用HMAC-SHA256计数器模式生成足够长的伪随机数，多生成8个字节，取模后的偏差可以忽略
+func (f *fpeCipher) round(i int, n int, m int, b []int) *big.Int {
+	size := len(f.modulus(m).Bytes()) + 8
+	header := []uint32{uint32(i), uint32(f.radix), uint32(n), uint32(len(f.tweak))}
+	out := []byte{}
+	for counter := uint32(0); len(out) < size; counter++ {
+		mac := hmac.New(sha256.New, f.secret)
+		binary.Write(mac, binary.BigEndian, header)
+		mac.Write(f.tweak)
+		for _, d := range b {
+			binary.Write(mac, binary.BigEndian, uint32(d))
+		}
+		binary.Write(mac, binary.BigEndian, counter)
+		out = mac.Sum(out)
+	}
+	return new(big.Int).SetBytes(out[:size])
+}
+
+func (f *fpeCipher) modulus(m int) *big.Int {
+	return new(big.Int).Exp(big.NewInt(int64(f.radix)), big.NewInt(int64(m)), nil)
+}
+
+//数字串转换成整数，高位在前
+func (f *fpeCipher) num(x []int) *big.Int {
+	res := new(big.Int)
+	radix := big.NewInt(int64(f.radix))
+	for _, d := range x {
+		res.Mul(res, radix)
+		res.Add(res, big.NewInt(int64(d)))
+	}
+	return res
+}
+
+//整数转换成m位数字串
+func (f *fpeCipher) str(c *big.Int, m int) []int {
+	res := make([]int, m)
+	radix := big.NewInt(int64(f.radix))
+	c = new(big.Int).Set(c)
+	d := new(big.Int)
+	for i := m - 1; i >= 0; i-- {
+		c.DivMod(c, radix, d)
+		res[i] = int(d.Int64())
+	}
+	return res
+}
@@ -0,0 +1,127 @@
+package jsonpath
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func Test_jsonpath_fpe_transform(t *testing.T) {
+	key := []byte("secret")
+	values := []string{"13800138000", "138-0013-8000", "E12345678", "张三丰", "alice@example.com", "7", "", "a1", strings.Repeat("9", 120)}
+	for idx, v := range values {
+		token := fpe_transform(key, "", v, true)
+		if v != "" && token == v {
+			t.Errorf("idx: %d, value: %s, token should differ from value", idx, v)
+		}
+		if fpe_transform(key, "", v, true) != token {
+			t.Errorf("idx: %d, value: %s, token should be deterministic", idx, v)
+		}
+		if res := fpe_transform(key, "", token, false); res != v {
+			t.Errorf("idx: %d, (got)%v != (exp)%v", idx, res, v)
+		}
+		//格式保持不变
+		tr, vr := []rune(token), []rune(v)
+		if len(tr) != len(vr) {
+			t.Errorf("idx: %d, value: %s, token: %s, length changed", idx, v, token)
+			continue
+		}
+		for i := range vr {
+			if fpe_class(tr[i]) != fpe_class(vr[i]) || (fpe_class(vr[i]) < 0 && tr[i] != vr[i]) {
+				t.Errorf("idx: %d, value: %s, token: %s, format changed at %d", idx, v, token, i)
+				break
+			}
+		}
+	}
+	v := "13800138000"
+	if fpe_transform([]byte("other"), "", v, true) == fpe_transform(key, "", v, true) {
+		t.Errorf("different keys should give different tokens")
+	}
+	if fpe_transform(key, "phone", v, true) == fpe_transform(key, "", v, true) {
+		t.Errorf("different tweaks should give different tokens")
+	}
+}
+
+func fpe_class(c rune) int {
+	for j, class := range fpeClasses {
+		if c >= class.first && c < class.first+rune(class.radix) {
+			return j
+		}
+	}
+	return -1
+}
+
+func Test_jsonpath_tokenize(t *testing.T) {
+	key := []byte("secret")
+	var orders, users interface{}
+	json.Unmarshal([]byte(`{"orders": [{"phone": "13800138000", "amount": 10}, {"phone": "13900139000", "amount": 20}, {"phone": 13800138000, "amount": 30}]}`), &orders)
+	json.Unmarshal([]byte(`{"users": [{"phone": "13800138000", "city": "Beijing"}]}`), &users)
+	origin := toString(orders)
+
+	c := MustCompile("$.orders[*].phone")
+	if _, err := c.LookupAndApply(orders, TokenizeOperator{Key: key, NonString: NonStringStringify}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := JsonPathLookUpAndTokenize(users, "$.users[*].phone", key); err != nil {
+		t.Fatal(err)
+	}
+	phones, _ := JsonPathLookUp(orders, "$.orders[*].phone")
+	user_phone, _ := JsonPathLookUp(users, "$.users[0].phone")
+	list := phones.([]interface{})
+	//同一个手机号得到相同的token，可以关联两份数据
+	if list[0] == "13800138000" || list[0] != user_phone || list[2] != user_phone || list[1] == list[0] {
+		t.Errorf("tokens should be deterministic, got: %v, %v", list, user_phone)
+	}
+
+	if _, err := c.LookupAndApply(orders, DetokenizeOperator{Key: key}); err != nil {
+		t.Fatal(err)
+	}
+	//数字还原后为字符串
+	expect := strings.Replace(origin, `"phone":13800138000}`, `"phone":"13800138000"}`, 1)
+	if toString(orders) != expect {
+		t.Errorf("(got)%v != (exp)%v", toString(orders), expect)
+	}
+	if _, err := JsonPathLookUpAndDetokenize(users, "$.users[*].phone", key); err != nil {
+		t.Fatal(err)
+	}
+	if res, _ := JsonPathLookUp(users, "$.users[0].phone"); res != "13800138000" {
+		t.Errorf("(got)%v != (exp)13800138000", res)
+	}
+
+	if _, err := JsonPathLookUpAndTokenize(users, "$.users[*].phone", nil); err == nil {
+		t.Errorf("empty key should return error")
+	}
+	var j interface{}
+	json.Unmarshal([]byte(`{"phone": 13800138000}`), &j)
+	if _, err := JsonPathLookUpAndApply(j, "$.phone", TokenizeOperator{Key: key, NonString: NonStringError}); err == nil {
+		t.Errorf("number should return error")
+	}
+	if _, err := JsonPathLookUpAndApply(j, "$.phone", TokenizeOperator{Key: key, NonString: NonStringSkip}); err != nil || !reflect.DeepEqual(j, map[string]interface{}{"phone": 13800138000.0}) {
+		t.Errorf("number should be skipped, got: %v, %v", j, err)
+	}
+}
+
+//存成数字的手机号默认也会被替换成token，还原后为字符串形式的手机号
+func Test_jsonpath_tokenize_number(t *testing.T) {
+	key := []byte("secret")
+	var j interface{}
+	json.Unmarshal([]byte(`{"users": [{"phone": 13800138000}, {"phone": "13800138000"}, {"phone": null}]}`), &j)
+	if _, err := JsonPathLookUpAndTokenize(j, "$.users[*].phone", key); err != nil {
+		t.Fatal(err)
+	}
+	phones, _ := JsonPathLookUp(j, "$.users[*].phone")
+	list := phones.([]interface{})
+	token, ok := list[0].(string)
+	if !ok || token == "13800138000" || len(token) != 11 || list[1] != token || list[2] != nil {
+		t.Fatalf("number should be tokenized like the string, got: %v", toString(list))
+	}
+	if _, err := JsonPathLookUpAndDetokenize(j, "$.users[*].phone", key); err != nil {
+		t.Fatal(err)
+	}
+	var expect interface{}
+	json.Unmarshal([]byte(`{"users": [{"phone": "13800138000"}, {"phone": "13800138000"}, {"phone": null}]}`), &expect)
+	if !reflect.DeepEqual(j, expect) {
+		t.Errorf("(got)%v != (exp)%v", toString(j), toString(expect))
+	}
+}
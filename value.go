@@ -0,0 +1,194 @@
+package jsonpath
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strconv"
+)
+
+//对象的所有键值，按字典序排列，保证遍历顺序稳定
+//obj不是键为字符串的map时ok为false
+func objectKeys(obj interface{}) (keys []string, ok bool) {
+	if jsonMap, ok := obj.(map[string]interface{}); ok {
+		keys = make([]string, 0, len(jsonMap))
+		for k := range jsonMap {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		return keys, true
+	}
+	v := reflect.ValueOf(obj)
+	if v.Kind() != reflect.Map || v.Type().Key().Kind() != reflect.String {
+		return nil, false
+	}
+	keys = make([]string, 0, v.Len())
+	for _, kv := range v.MapKeys() {
+		keys = append(keys, kv.String())
+	}
+	sort.Strings(keys)
+	return keys, true
+}
+
+//obj是否是键为字符串的map，只判断类型时使用，不需要像objectKeys一样取出并排序键值
+func isObject(obj interface{}) bool {
+	if _, ok := obj.(map[string]interface{}); ok {
+		return true
+	}
+	v := reflect.ValueOf(obj)
+	return v.Kind() == reflect.Map && v.Type().Key().Kind() == reflect.String
+}
+
+//获取对象中key对应的值，obj不是对象或key不存在时ok为false
+func objectGet(obj interface{}, key string) (interface{}, bool) {
+	if jsonMap, ok := obj.(map[string]interface{}); ok {
+		val, exists := jsonMap[key]
+		return val, exists
+	}
+	v := reflect.ValueOf(obj)
+	if v.Kind() != reflect.Map || v.Type().Key().Kind() != reflect.String {
+		return nil, false
+	}
+	val := v.MapIndex(reflect.ValueOf(key).Convert(v.Type().Key()))
+	if !val.IsValid() {
+		return nil, false
+	}
+	return val.Interface(), true
+}
+
+//数组的长度，obj不是数组时ok为false
+func arrayLen(obj interface{}) (int, bool) {
+	if arr, ok := obj.([]interface{}); ok {
+		return len(arr), true
+	}
+	v := reflect.ValueOf(obj)
+	if v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
+		return 0, false
+	}
+	return v.Len(), true
+}
+
+//获取数组中下标为idx的元素，调用前需要保证下标合法
+func arrayGet(obj interface{}, idx int) interface{} {
+	if arr, ok := obj.([]interface{}); ok {
+		return arr[idx]
+	}
+	return reflect.ValueOf(obj).Index(idx).Interface()
+}
+
+//将json中的数字转换成float64，obj不是数字时ok为false
+func toNumber(obj interface{}) (float64, bool) {
+	switch v := obj.(type) {
+	case float64:
+		return v, true
+	case float32:
+		return float64(v), true
+	case int:
+		return float64(v), true
+	case int8:
+		return float64(v), true
+	case int16:
+		return float64(v), true
+	case int32:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case uint:
+		return float64(v), true
+	case uint8:
+		return float64(v), true
+	case uint16:
+		return float64(v), true
+	case uint32:
+		return float64(v), true
+	case uint64:
+		return float64(v), true
+	case json.Number:
+		f, err := strconv.ParseFloat(string(v), 64)
+		return f, err == nil
+	}
+	return 0, false
+}
+
+//按json的语义判断两个值是否相等，数字按数值比较，数组和对象逐个元素比较
+func valueEqual(a, b interface{}) bool {
+	if a == nothing || b == nothing {
+		return a == nothing && b == nothing
+	}
+	fa, aNum := toNumber(a)
+	fb, bNum := toNumber(b)
+	if aNum || bNum {
+		return aNum && bNum && fa == fb
+	}
+	switch av := a.(type) {
+	case nil:
+		return b == nil
+	case string:
+		bv, ok := b.(string)
+		return ok && av == bv
+	case bool:
+		bv, ok := b.(bool)
+		return ok && av == bv
+	}
+	if aKeys, ok := objectKeys(a); ok {
+		bKeys, ok := objectKeys(b)
+		if !ok || len(aKeys) != len(bKeys) {
+			return false
+		}
+		for i, k := range aKeys {
+			if bKeys[i] != k {
+				return false
+			}
+			av, _ := objectGet(a, k)
+			bv, _ := objectGet(b, k)
+			if !valueEqual(av, bv) {
+				return false
+			}
+		}
+		return true
+	}
+	if aLen, ok := arrayLen(a); ok {
+		bLen, ok := arrayLen(b)
+		if !ok || aLen != bLen {
+			return false
+		}
+		for i := 0; i < aLen; i++ {
+			if !valueEqual(arrayGet(a, i), arrayGet(b, i)) {
+				return false
+			}
+		}
+		return true
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+//a是否小于b，只有两个数字或两个字符串之间可以比较
+func valueLess(a, b interface{}) bool {
+	fa, aNum := toNumber(a)
+	fb, bNum := toNumber(b)
+	if aNum && bNum {
+		return fa < fb
+	}
+	as, aStr := a.(string)
+	bs, bStr := b.(string)
+	return aStr && bStr && as < bs
+}
+
+//按RFC 9535的语义比较两个值
+func compareValues(a, b interface{}, op string) bool {
+	switch op {
+	case "==":
+		return valueEqual(a, b)
+	case "!=":
+		return !valueEqual(a, b)
+	case "<":
+		return valueLess(a, b)
+	case ">":
+		return valueLess(b, a)
+	case "<=":
+		return valueLess(a, b) || valueEqual(a, b)
+	case ">=":
+		return valueLess(b, a) || valueEqual(a, b)
+	}
+	return false
+}